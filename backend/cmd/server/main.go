@@ -3,9 +3,16 @@ package main
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"safebase-backend/internal/api"
 	"safebase-backend/internal/database"
+	"safebase-backend/internal/keyring"
 	"safebase-backend/internal/scheduler"
+	"safebase-backend/internal/secrets"
+	"strconv"
+	"time"
+	// Embedded zone database, for schedule time zones on hosts without one
+	_ "time/tzdata"
 )
 
 func main() {
@@ -29,7 +36,99 @@ func main() {
 		log.Fatal("Failed to initialize database:", err)
 	}
 
+	credentialKeys, err := keyring.LoadCredentialKeys(filepath.Join(filepath.Dir(dbPath), "credentials.key"))
+	if err != nil {
+		log.Fatal("Failed to load credential keys:", err)
+	}
+	database.SetCredentialKeys(credentialKeys)
+
+	// "server rekey-credentials" seals every stored credential with the primary credential key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rekey-credentials" {
+		rekeyed, err := database.RekeyCredentials(true)
+		if err != nil {
+			log.Fatal("Credential re-keying failed:", err)
+		}
+		log.Printf("Sealed %d credentials with key %s", rekeyed, credentialKeys.PrimaryID())
+		return
+	}
+	// Credentials stored in plaintext, or sealed without their row, by earlier versions are sealed on start
+	if sealed, err := database.RekeyCredentials(false); err != nil {
+		log.Fatal("Failed to seal stored credentials:", err)
+	} else if sealed > 0 {
+		log.Printf("Sealed %d stored credentials", sealed)
+	}
+
+	keys, err := keyring.LoadFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load encryption keys:", err)
+	}
+
+	// "server rotate-keys" re-wraps backup data keys with the primary key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		if err := rotateKeys(keys); err != nil {
+			log.Fatal("Key rotation failed:", err)
+		}
+		return
+	}
+
 	sched := scheduler.NewScheduler(backupDir)
+	// SQLite databases are files of this server: only those within
+	// SQLITE_ROOT may be backed up or restored into
+	sched.BackupExec.SQLiteRoot = os.Getenv("SQLITE_ROOT")
+	if sched.BackupExec.SQLiteRoot == "" {
+		sched.BackupExec.SQLiteRoot = filepath.Join(filepath.Dir(dbPath), "sqlite")
+	}
+
+	// Opt-in scheduled backup of SafeBase's own metadata database
+	if selfBackupCron := os.Getenv("SELF_BACKUP_CRON"); selfBackupCron != "" {
+		self, err := database.EnsureSelfBackup(dbPath, selfBackupCron)
+		if err != nil {
+			log.Printf("Failed to register self backup: %v", err)
+		} else {
+			sched.BackupExec.Self = self
+		}
+	}
+
+	sched.BackupExec.Keyring = keys
+	sched.BackupExec.Secrets, err = secrets.LoadFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure secret providers:", err)
+	}
+	if workers := os.Getenv("BACKUP_WORKERS"); workers != "" {
+		n, err := strconv.Atoi(workers)
+		if err != nil || n <= 0 {
+			log.Fatal("Invalid BACKUP_WORKERS:", workers)
+		}
+		sched.Jobs.Workers = n
+	}
+	if perHost := os.Getenv("BACKUP_MAX_PER_HOST"); perHost != "" {
+		n, err := strconv.Atoi(perHost)
+		if err != nil || n < 0 {
+			log.Fatal("Invalid BACKUP_MAX_PER_HOST:", perHost)
+		}
+		sched.Jobs.MaxPerHost = n
+	}
+	if perStorage := os.Getenv("BACKUP_MAX_PER_STORAGE"); perStorage != "" {
+		n, err := strconv.Atoi(perStorage)
+		if err != nil || n < 0 {
+			log.Fatal("Invalid BACKUP_MAX_PER_STORAGE:", perStorage)
+		}
+		sched.Jobs.MaxPerStorage = n
+	}
+	if verifyInterval := os.Getenv("BACKUP_VERIFY_INTERVAL"); verifyInterval != "" {
+		interval, err := time.ParseDuration(verifyInterval)
+		if err != nil || interval <= 0 {
+			log.Fatal("Invalid BACKUP_VERIFY_INTERVAL:", verifyInterval)
+		}
+		sched.VerifyInterval = interval
+	}
+	if healthInterval := os.Getenv("DB_HEALTH_INTERVAL"); healthInterval != "" {
+		interval, err := time.ParseDuration(healthInterval)
+		if err != nil || interval <= 0 {
+			log.Fatal("Invalid DB_HEALTH_INTERVAL:", healthInterval)
+		}
+		sched.HealthInterval = interval
+	}
 	sched.Start()
 	defer sched.Stop()
 
@@ -39,8 +138,11 @@ func main() {
 	log.Printf("Server starting on port %s", port)
 	log.Printf("Backup directory: %s", backupDir)
 	log.Printf("Database path: %s", dbPath)
-	
+	if keys != nil {
+		log.Printf("Backup encryption enabled (primary key: %s)", keys.PrimaryID())
+	}
+
 	if err := router.Run(":" + port); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
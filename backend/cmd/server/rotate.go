@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/keyring"
+	"safebase-backend/internal/models"
+)
+
+// rotateKeys re-wraps the data key of every backup that is not yet wrapped
+// by the primary master key. Artifacts are not rewritten: only the wrapped
+// key stored on the backup row changes.
+func rotateKeys(keys *keyring.Keyring) error {
+	if keys == nil {
+		return fmt.Errorf("no encryption keys configured")
+	}
+
+	var backups []models.Backup
+	err := database.DB.Where("encryption_key_id <> '' AND encryption_key_id <> ?", keys.PrimaryID()).Find(&backups).Error
+	if err != nil {
+		return err
+	}
+
+	rotated := 0
+	for _, backup := range backups {
+		wrapped, err := base64.StdEncoding.DecodeString(backup.EncryptedDataKey)
+		if err != nil {
+			log.Printf("Skipping backup %s: invalid wrapped data key: %v", backup.ID, err)
+			continue
+		}
+
+		keyID, rewrapped, err := keys.Rewrap(backup.EncryptionKeyID, wrapped, []byte(backup.ID))
+		if err != nil {
+			log.Printf("Skipping backup %s: %v", backup.ID, err)
+			continue
+		}
+
+		err = database.DB.Model(&models.Backup{}).Where("id = ?", backup.ID).Updates(map[string]interface{}{
+			"encryption_key_id":  keyID,
+			"encrypted_data_key": base64.StdEncoding.EncodeToString(rewrapped),
+		}).Error
+		if err != nil {
+			return err
+		}
+		rotated++
+	}
+
+	log.Printf("Re-wrapped %d of %d backup keys with key %s", rotated, len(backups), keys.PrimaryID())
+	if rotated != len(backups) {
+		return fmt.Errorf("%d backup keys could not be re-wrapped", len(backups)-rotated)
+	}
+	return nil
+}
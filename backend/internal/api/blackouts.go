@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/scheduler"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func validateBlackout(w models.BlackoutWindow) (int, string) {
+	if err := scheduler.ValidateBlackout(w); err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	if w.DatabaseID != "" {
+		var db models.Database
+		if err := database.DB.First(&db, "id = ?", w.DatabaseID).Error; err != nil {
+			return http.StatusBadRequest, "Database not found"
+		}
+	}
+	return 0, ""
+}
+
+func (h *Handler) GetBlackouts(c *gin.Context) {
+	var windows []models.BlackoutWindow
+	query := database.DB
+	if databaseID := c.Query("databaseId"); databaseID != "" {
+		query = query.Where("database_id = ? OR database_id = ?", databaseID, "")
+	}
+	query.Order("created_at").Find(&windows)
+	c.JSON(http.StatusOK, windows)
+}
+
+func (h *Handler) CreateBlackout(c *gin.Context) {
+	var window models.BlackoutWindow
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, msg := validateBlackout(window); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	window.ID = uuid.New().String()
+	window.CreatedAt = time.Now()
+	window.UpdatedAt = time.Now()
+
+	if err := database.DB.Create(&window).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, window)
+}
+
+func (h *Handler) UpdateBlackout(c *gin.Context) {
+	id := c.Param("id")
+	var window models.BlackoutWindow
+	if err := database.DB.First(&window, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Blackout window not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&window); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, msg := validateBlackout(window); status != 0 {
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	window.ID = id
+	window.UpdatedAt = time.Now()
+	database.DB.Save(&window)
+	c.JSON(http.StatusOK, window)
+}
+
+func (h *Handler) DeleteBlackout(c *gin.Context) {
+	database.DB.Delete(&models.BlackoutWindow{}, "id = ?", c.Param("id"))
+	c.Status(http.StatusNoContent)
+}
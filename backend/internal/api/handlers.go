@@ -1,11 +1,17 @@
 package api
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"safebase-backend/internal/backup"
 	"safebase-backend/internal/database"
+	"safebase-backend/internal/jobs"
 	"safebase-backend/internal/models"
 	"safebase-backend/internal/scheduler"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,9 +26,65 @@ func NewHandler(s *scheduler.Scheduler) *Handler {
 	return &Handler{scheduler: s}
 }
 
+// redactDatabase strips credentials before a database is sent back to the
+// browser. Updates that omit the password or SSH key, or send the redacted
+// connection URI back unchanged, keep the stored values, unless they point
+// the database at another server.
+func redactDatabase(db models.Database) models.Database {
+	db.HasPassword = db.Password != ""
+	db.Password = ""
+	db.HasSSHPrivateKey = db.SSHPrivateKey != ""
+	db.SSHPrivateKey = ""
+	db.ConnectionURI = redactURI(db.ConnectionURI)
+	return db
+}
+
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// sameServer reports whether the update db still connects as the same user
+// to the same server as stored, directly or through the same bastion. Stored
+// secrets are only kept for that server, so that a saved database cannot be
+// pointed at another one to collect them.
+func sameServer(stored, db models.Database) bool {
+	return stored.Type == db.Type &&
+		stored.Host == db.Host &&
+		stored.Port == db.Port &&
+		stored.Username == db.Username &&
+		(stored.ConnectionURI == db.ConnectionURI || (db.ConnectionURI != "" && redactURI(stored.ConnectionURI) == redactURI(db.ConnectionURI))) &&
+		stored.SSHHost == db.SSHHost &&
+		stored.SSHUser == db.SSHUser &&
+		stored.SSHKnownHosts == db.SSHKnownHosts
+}
+
+// usePasswordRef checks the secret reference of db, if any. A database
+// referencing a secret keeps no password of its own.
+func (h *Handler) usePasswordRef(db *models.Database) error {
+	if db.PasswordRef == "" {
+		return nil
+	}
+	resolver := h.scheduler.BackupExec.Secrets
+	if resolver == nil {
+		return errors.New("no secret providers configured")
+	}
+	if err := resolver.Validate(db.PasswordRef); err != nil {
+		return err
+	}
+	db.Password = ""
+	return nil
+}
+
 func (h *Handler) GetDatabases(c *gin.Context) {
 	var databases []models.Database
 	database.DB.Find(&databases)
+	for i := range databases {
+		databases[i] = redactDatabase(databases[i])
+	}
 	c.JSON(http.StatusOK, databases)
 }
 
@@ -33,7 +95,7 @@ func (h *Handler) GetDatabase(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Database not found"})
 		return
 	}
-	c.JSON(http.StatusOK, db)
+	c.JSON(http.StatusOK, redactDatabase(db))
 }
 
 func (h *Handler) CreateDatabase(c *gin.Context) {
@@ -43,8 +105,34 @@ func (h *Handler) CreateDatabase(c *gin.Context) {
 		return
 	}
 
+	if _, err := backup.GetDriver(db.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateCompression(db.Compression, db.CompressionLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.usePasswordRef(&db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateTunnel(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	db.ID = uuid.New().String()
-	db.Status = "connected"
+	if err := h.scheduler.BackupExec.ValidateSQLite(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Known once the first probe, started below, has connected
+	db.Status = "unknown"
 	db.CreatedAt = time.Now()
 	db.UpdatedAt = time.Now()
 
@@ -52,8 +140,9 @@ func (h *Handler) CreateDatabase(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	go h.scheduler.ProbeDatabase(db)
 
-	c.JSON(http.StatusCreated, db)
+	c.JSON(http.StatusCreated, redactDatabase(db))
 }
 
 func (h *Handler) UpdateDatabase(c *gin.Context) {
@@ -63,15 +152,104 @@ func (h *Handler) UpdateDatabase(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Database not found"})
 		return
 	}
+	stored := db
 
 	if err := c.ShouldBindJSON(&db); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	keepsURI := db.ConnectionURI == redactURI(stored.ConnectionURI) && db.ConnectionURI != stored.ConnectionURI
+	if !sameServer(stored, db) {
+		if (db.Password == "" && db.PasswordRef == "" && stored.Password != "") || (db.SSHPrivateKey == "" && stored.SSHPrivateKey != "") || keepsURI {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Credentials must be entered again when the connection changes"})
+			return
+		}
+	}
+	if db.Password == "" {
+		db.Password = stored.Password
+	}
+	if db.SSHPrivateKey == "" {
+		db.SSHPrivateKey = stored.SSHPrivateKey
+	}
+	if keepsURI {
+		db.ConnectionURI = stored.ConnectionURI
+	}
+
+	if _, err := backup.GetDriver(db.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateCompression(db.Compression, db.CompressionLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.usePasswordRef(&db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateTunnel(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db.ID = id
+	if err := h.scheduler.BackupExec.ValidateSQLite(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	db.UpdatedAt = time.Now()
 	database.DB.Save(&db)
-	c.JSON(http.StatusOK, db)
+	go h.scheduler.ProbeDatabase(db)
+	c.JSON(http.StatusOK, redactDatabase(db))
+}
+
+// TestDatabaseConnection connects with settings that are not saved yet.
+func (h *Handler) TestDatabaseConnection(c *gin.Context) {
+	var db models.Database
+	if err := c.ShouldBindJSON(&db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := backup.GetDriver(db.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.usePasswordRef(&db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateTunnel(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Unsaved settings are never SafeBase's own database
+	db.ID = ""
+	if err := h.scheduler.BackupExec.ValidateSQLite(db); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.scheduler.BackupExec.Probe(db))
+}
+
+// TestDatabase probes a saved database and records its status.
+func (h *Handler) TestDatabase(c *gin.Context) {
+	id := c.Param("id")
+	var db models.Database
+	if err := database.DB.First(&db, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Database not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.scheduler.ProbeDatabase(db))
 }
 
 func (h *Handler) DeleteDatabase(c *gin.Context) {
@@ -80,6 +258,10 @@ func (h *Handler) DeleteDatabase(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+func (h *Handler) GetDrivers(c *gin.Context) {
+	c.JSON(http.StatusOK, backup.DriverTypes())
+}
+
 func (h *Handler) GetSchedules(c *gin.Context) {
 	var schedules []models.BackupSchedule
 	database.DB.Find(&schedules)
@@ -109,6 +291,46 @@ func (h *Handler) CreateSchedule(c *gin.Context) {
 		return
 	}
 
+	if _, err := scheduler.ParseCron(schedule.CronExpression); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cron expression: " + err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone: " + schedule.Timezone})
+		return
+	}
+
+	if !scheduler.ValidMisfirePolicy(schedule.MisfirePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown misfire policy: " + schedule.MisfirePolicy})
+		return
+	}
+
+	if err := backup.ValidateCompression(schedule.Compression, schedule.CompressionLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateStorageTargets(append([]string{schedule.StorageTargetID}, schedule.ReplicaTargetIDs...)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateRetention(backup.RetentionFor(schedule)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateRetry(backup.RetryFor(schedule)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateScheduleType(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	schedule.ID = uuid.New().String()
 	schedule.DatabaseName = db.Name
 	schedule.CreatedAt = time.Now()
@@ -136,6 +358,46 @@ func (h *Handler) UpdateSchedule(c *gin.Context) {
 		return
 	}
 
+	if _, err := scheduler.ParseCron(schedule.CronExpression); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cron expression: " + err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone: " + schedule.Timezone})
+		return
+	}
+
+	if !scheduler.ValidMisfirePolicy(schedule.MisfirePolicy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown misfire policy: " + schedule.MisfirePolicy})
+		return
+	}
+
+	if err := backup.ValidateCompression(schedule.Compression, schedule.CompressionLevel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateStorageTargets(append([]string{schedule.StorageTargetID}, schedule.ReplicaTargetIDs...)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateRetention(backup.RetentionFor(schedule)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := backup.ValidateRetry(backup.RetryFor(schedule)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateScheduleType(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	schedule.UpdatedAt = time.Now()
 	database.DB.Save(&schedule)
 	h.scheduler.UpdateSchedule(schedule)
@@ -159,14 +421,14 @@ func (h *Handler) GetBackups(c *gin.Context) {
 		query = query.Where("database_id = ?", databaseID)
 	}
 
-	query.Order("created_at DESC").Limit(100).Find(&backups)
+	query.Preload("Replicas").Order("created_at DESC").Limit(100).Find(&backups)
 	c.JSON(http.StatusOK, backups)
 }
 
 func (h *Handler) GetBackup(c *gin.Context) {
 	id := c.Param("id")
 	var backup models.Backup
-	if err := database.DB.First(&backup, "id = ?", id).Error; err != nil {
+	if err := database.DB.Preload("Replicas").First(&backup, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
 		return
 	}
@@ -189,30 +451,94 @@ func (h *Handler) CreateManualBackup(c *gin.Context) {
 		return
 	}
 
-	backup, err := h.scheduler.BackupExec.ExecuteBackup(db, "")
+	job, err := h.scheduler.EnqueueBackup(db)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	backup.Type = "manual"
-	database.DB.Create(&backup)
+	c.JSON(http.StatusAccepted, job)
+}
 
-	now := time.Now()
-	db.LastBackup = &now
-	db.BackupCount++
-	database.DB.Save(&db)
+// RestoreBackup queues the restore of a backup into its database, or into
+// targetDatabaseId, and returns the job.
+func (h *Handler) RestoreBackup(c *gin.Context) {
+	var req struct {
+		TargetDatabaseID string `json:"targetDatabaseId"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusCreated, backup)
+	id := c.Param("id")
+	var backup models.Backup
+	if err := database.DB.First(&backup, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		return
+	}
+	if backup.Status != "success" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Backup is not restorable"})
+		return
+	}
+
+	targetID := req.TargetDatabaseID
+	if targetID == "" {
+		targetID = backup.DatabaseID
+	}
+
+	var target models.Database
+	if err := database.DB.First(&target, "id = ?", targetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target database not found"})
+		return
+	}
+
+	job, err := h.scheduler.EnqueueRestore(backup, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
 }
 
-func (h *Handler) ExecuteSchedule(c *gin.Context) {
-	defer func() {
-		if r := recover(); r != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		}
-	}()
+// VerifyBackup re-reads a stored backup and checks its checksum and dump
+// structure, marking it corrupted when the artifact is damaged.
+func (h *Handler) VerifyBackup(c *gin.Context) {
+	id := c.Param("id")
+	var b models.Backup
+	if err := database.DB.First(&b, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		return
+	}
+
+	if b.Status != "success" && b.Status != "corrupted" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only completed backups can be verified"})
+		return
+	}
+
+	if err := h.scheduler.VerifyBackup(&b); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+func (h *Handler) GetRestores(c *gin.Context) {
+	var restores []models.Restore
+	query := database.DB
+
+	if backupID := c.Query("backupId"); backupID != "" {
+		query = query.Where("backup_id = ?", backupID)
+	}
 
+	query.Order("created_at DESC").Limit(100).Find(&restores)
+	c.JSON(http.StatusOK, restores)
+}
+
+func (h *Handler) ExecuteSchedule(c *gin.Context) {
 	id := c.Param("id")
 	var schedule models.BackupSchedule
 	if err := database.DB.First(&schedule, "id = ?", id).Error; err != nil {
@@ -226,37 +552,191 @@ func (h *Handler) ExecuteSchedule(c *gin.Context) {
 		return
 	}
 
-	backup, err := h.scheduler.BackupExec.ExecuteBackup(db, schedule.ID)
-	
+	job, err := h.scheduler.EnqueueSchedule(schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+func (h *Handler) GetJobs(c *gin.Context) {
+	query := database.DB.Order("created_at DESC").Limit(100)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var jobList []models.Job
+	query.Find(&jobList)
+	c.JSON(http.StatusOK, jobList)
+}
+
+func (h *Handler) GetJob(c *gin.Context) {
+	job, err := jobs.Get(c.Param("id"))
 	if err != nil {
-		backup.Type = "scheduled"
-		database.DB.Create(&backup)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "backup": backup})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
 		return
 	}
+	c.JSON(http.StatusOK, job)
+}
 
-	if err := database.DB.Create(&backup).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save backup: " + err.Error()})
+// PruneSchedule applies the retention policy of a schedule. With
+// ?dryRun=true the backups that would be deleted are listed but kept.
+func (h *Handler) PruneSchedule(c *gin.Context) {
+	id := c.Param("id")
+	var schedule models.BackupSchedule
+	if err := database.DB.First(&schedule, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
 		return
 	}
 
-	now := time.Now()
-	database.UpdateScheduleLastRun(schedule.ID, now)
-	
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Panic in CalculateAndUpdateNextRun: %v", r)
-			}
-		}()
-		h.scheduler.CalculateAndUpdateNextRun(schedule)
-	}()
+	dryRun := c.Query("dryRun") == "true"
+	backups, err := h.scheduler.Prune(schedule, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	db.LastBackup = &now
-	db.BackupCount++
-	database.DB.Save(&db)
+	var freed int64
+	for _, b := range backups {
+		freed += b.SizeBytes
+	}
+	c.JSON(http.StatusOK, gin.H{"dryRun": dryRun, "backups": backups, "freedBytes": freed})
+}
+
+func (h *Handler) GetDrills(c *gin.Context) {
+	query := database.DB.Order("created_at DESC")
+	if databaseID := c.Query("databaseId"); databaseID != "" {
+		query = query.Where("database_id = ?", databaseID)
+	}
 
-	c.JSON(http.StatusCreated, backup)
+	var drills []models.RestoreDrill
+	query.Find(&drills)
+	c.JSON(http.StatusOK, drills)
+}
+
+// validateScheduleType checks the settings specific to restore drill
+// schedules.
+func validateScheduleType(schedule models.BackupSchedule) error {
+	switch schedule.Type {
+	case "", backup.ScheduleTypeBackup:
+		return nil
+	case backup.ScheduleTypeRestoreTest:
+	default:
+		return fmt.Errorf("unknown schedule type: %s", schedule.Type)
+	}
+
+	var db, server models.Database
+	if err := database.DB.First(&db, "id = ?", schedule.DatabaseID).Error; err != nil {
+		return errors.New("database not found")
+	}
+	if err := database.DB.First(&server, "id = ?", schedule.SandboxDatabaseID).Error; err != nil {
+		return errors.New("sandbox server not found")
+	}
+	if err := backup.ValidateDrill(db, server); err != nil {
+		return err
+	}
+
+	for _, check := range schedule.SanityChecks {
+		if strings.TrimSpace(check.Query) == "" {
+			return errors.New("sanity checks need a query")
+		}
+	}
+	return nil
+}
+
+// CancelJob stops a queued or running job. A running backup has its dump
+// process killed and its partial artifact removed, and is recorded as
+// cancelled.
+func (h *Handler) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := jobs.Get(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	cancelled, err := h.scheduler.Jobs.Cancel(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job has already finished"})
+		return
+	}
+
+	job, _ := jobs.Get(id)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// StreamJobEvents streams the progress of a job as Server-Sent Events: its
+// current state, the events it already published, then live phase, bytes,
+// log and status events until it finishes. Only the replica running a job
+// publishes its events, so its status is also polled from the database: the
+// stream then still reports status changes and ends with the job.
+func (h *Handler) StreamJobEvents(c *gin.Context) {
+	id := c.Param("id")
+	// Subscribe before reading the job so no event falls in between
+	past, events, cancel := h.scheduler.Jobs.Subscribe(id)
+	defer cancel()
+
+	job, err := jobs.Get(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent(jobs.EventStatus, job)
+	for _, event := range past {
+		c.SSEvent(event.Type, event.Data)
+	}
+	c.Writer.Flush()
+	if jobs.Finished(job.Status) {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	poll := time.NewTicker(5 * time.Second)
+	defer poll.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if current, isJob := event.Data.(models.Job); isJob && event.Type == jobs.EventStatus {
+				job = current
+			}
+			c.SSEvent(event.Type, event.Data)
+		case <-poll.C:
+			current, err := jobs.Get(id)
+			if err != nil {
+				return
+			}
+			if current.Status != job.Status {
+				job = current
+				c.SSEvent(jobs.EventStatus, job)
+			}
+			if jobs.Finished(job.Status) {
+				c.Writer.Flush()
+				return
+			}
+			continue
+		case <-heartbeat.C:
+			c.Writer.WriteString(": keepalive\n\n")
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
 }
 
 func (h *Handler) GetAlerts(c *gin.Context) {
@@ -288,4 +768,3 @@ func (h *Handler) GetUnreadCount(c *gin.Context) {
 	database.DB.Model(&models.Alert{}).Where("read = ?", false).Count(&count)
 	c.JSON(http.StatusOK, gin.H{"count": count})
 }
-
@@ -17,7 +17,7 @@ func SetupRoutes(handler *Handler) *gin.Engine {
 	// Health check endpoint (no authentication)
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
-			"status": "ok",
+			"status":  "ok",
 			"service": "safebase-backend",
 		})
 	})
@@ -44,6 +44,9 @@ func SetupRoutes(handler *Handler) *gin.Engine {
 			protected.POST("/databases", handler.CreateDatabase)
 			protected.PUT("/databases/:id", handler.UpdateDatabase)
 			protected.DELETE("/databases/:id", handler.DeleteDatabase)
+			protected.POST("/databases/test", handler.TestDatabaseConnection)
+			protected.POST("/databases/:id/test", handler.TestDatabase)
+			protected.GET("/drivers", handler.GetDrivers)
 
 			protected.GET("/schedules", handler.GetSchedules)
 			protected.GET("/schedules/:id", handler.GetSchedule)
@@ -51,18 +54,38 @@ func SetupRoutes(handler *Handler) *gin.Engine {
 			protected.PUT("/schedules/:id", handler.UpdateSchedule)
 			protected.DELETE("/schedules/:id", handler.DeleteSchedule)
 			protected.POST("/schedules/:id/execute", handler.ExecuteSchedule)
+			protected.POST("/schedules/:id/prune", handler.PruneSchedule)
 
-		protected.GET("/backups", handler.GetBackups)
-		protected.GET("/backups/:id", handler.GetBackup)
-		protected.POST("/backups/manual", handler.CreateManualBackup)
+			protected.GET("/backups", handler.GetBackups)
+			protected.GET("/backups/:id", handler.GetBackup)
+			protected.POST("/backups/manual", handler.CreateManualBackup)
+			protected.POST("/backups/:id/restore", handler.RestoreBackup)
+			protected.POST("/backups/:id/verify", handler.VerifyBackup)
 
-		protected.GET("/alerts", handler.GetAlerts)
-		protected.PUT("/alerts/:id/read", handler.MarkAlertAsRead)
-		protected.POST("/alerts/mark-all-read", handler.MarkAllAlertsAsRead)
-		protected.GET("/alerts/unread-count", handler.GetUnreadCount)
+			protected.GET("/restores", handler.GetRestores)
+			protected.GET("/drills", handler.GetDrills)
+
+			protected.GET("/jobs", handler.GetJobs)
+			protected.GET("/jobs/:id", handler.GetJob)
+			protected.GET("/jobs/:id/events", handler.StreamJobEvents)
+			protected.POST("/jobs/:id/cancel", handler.CancelJob)
+
+			protected.GET("/blackouts", handler.GetBlackouts)
+			protected.POST("/blackouts", handler.CreateBlackout)
+			protected.PUT("/blackouts/:id", handler.UpdateBlackout)
+			protected.DELETE("/blackouts/:id", handler.DeleteBlackout)
+
+			protected.GET("/storage-targets", handler.GetStorageTargets)
+			protected.POST("/storage-targets", handler.CreateStorageTarget)
+			protected.PUT("/storage-targets/:id", handler.UpdateStorageTarget)
+			protected.DELETE("/storage-targets/:id", handler.DeleteStorageTarget)
+
+			protected.GET("/alerts", handler.GetAlerts)
+			protected.PUT("/alerts/:id/read", handler.MarkAlertAsRead)
+			protected.POST("/alerts/mark-all-read", handler.MarkAllAlertsAsRead)
+			protected.GET("/alerts/unread-count", handler.GetUnreadCount)
+		}
 	}
-}
 
 	return r
 }
-
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"net/http"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// redactStorageTarget strips credentials before a target is sent back to the
+// browser. Updates that omit them keep the stored values.
+func redactStorageTarget(target models.StorageTarget) models.StorageTarget {
+	target.SecretKey = ""
+	target.Password = ""
+	target.PrivateKey = ""
+	return target
+}
+
+func validateStorageTargets(targetIDs []string) error {
+	for _, id := range targetIDs {
+		if id == "" {
+			continue
+		}
+		if _, err := database.OpenStorageTarget(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) GetStorageTargets(c *gin.Context) {
+	var targets []models.StorageTarget
+	database.DB.Find(&targets)
+	for i := range targets {
+		targets[i] = redactStorageTarget(targets[i])
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+func (h *Handler) CreateStorageTarget(c *gin.Context) {
+	var target models.StorageTarget
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := storage.Open(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target.ID = uuid.New().String()
+	target.CreatedAt = time.Now()
+	target.UpdatedAt = time.Now()
+
+	if err := database.DB.Create(&target).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, redactStorageTarget(target))
+}
+
+func (h *Handler) UpdateStorageTarget(c *gin.Context) {
+	id := c.Param("id")
+	var target models.StorageTarget
+	if err := database.DB.First(&target, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Storage target not found"})
+		return
+	}
+
+	if err := c.ShouldBindJSON(&target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := storage.Open(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target.ID = id
+	target.UpdatedAt = time.Now()
+	database.DB.Save(&target)
+	c.JSON(http.StatusOK, redactStorageTarget(target))
+}
+
+// storageTargetInUse says what still refers to the target id, if anything:
+// backups or replicas of any status, whose artifacts could not be pruned
+// without it, or databases and schedules that would write to it.
+func storageTargetInUse(id string) string {
+	var count int64
+	if database.DB.Model(&models.Backup{}).Where("storage_target_id = ?", id).Count(&count); count > 0 {
+		return "still holds backups"
+	}
+	if database.DB.Model(&models.BackupReplica{}).Where("storage_target_id = ?", id).Count(&count); count > 0 {
+		return "still holds backup replicas"
+	}
+	if database.DB.Model(&models.Database{}).Where("storage_target_id = ?", id).Count(&count); count > 0 {
+		return "is used by databases"
+	}
+
+	var schedules []models.BackupSchedule
+	database.DB.Select("storage_target_id", "replica_target_ids").Find(&schedules)
+	for _, schedule := range schedules {
+		if schedule.StorageTargetID == id {
+			return "is used by schedules"
+		}
+		for _, targetID := range schedule.ReplicaTargetIDs {
+			if targetID == id {
+				return "is used by schedules"
+			}
+		}
+	}
+	return ""
+}
+
+func (h *Handler) DeleteStorageTarget(c *gin.Context) {
+	id := c.Param("id")
+
+	if reason := storageTargetInUse(id); reason != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Storage target " + reason})
+		return
+	}
+
+	database.DB.Delete(&models.StorageTarget{}, "id = ?", id)
+	c.Status(http.StatusNoContent)
+}
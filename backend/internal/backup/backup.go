@@ -1,12 +1,19 @@
 package backup
 
 import (
-	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"safebase-backend/internal/keyring"
 	"safebase-backend/internal/models"
+	"safebase-backend/internal/secrets"
+	"safebase-backend/internal/storage"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,8 +36,36 @@ func findCommand(cmd string) string {
 	return cmd
 }
 
+// commandWaitDelay bounds how long a cancelled command may keep its output
+// pipes open, e.g. through a grandchild that escaped the kill.
+const commandWaitDelay = 5 * time.Second
+
+// commandContext is exec.CommandContext for dump tools: cancelling ctx
+// kills the tool and every process it started.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessTree(cmd)
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
 type BackupExecutor struct {
 	BackupDir string
+	// Keyring enables envelope encryption of every artifact when set
+	Keyring *keyring.Keyring
+	// OpenStorage resolves a configured storage target by ID. Artifacts
+	// without a target are kept in BackupDir.
+	OpenStorage func(targetID string) (storage.Storage, error)
+	// Secrets resolves Database.PasswordRef
+	Secrets *secrets.Resolver
+	// SQLiteRoot is the directory SQLite databases, and the sandboxes of
+	// their restore drills, must be within: their paths are files of this
+	// server, which users must not read or overwrite at will
+	SQLiteRoot string
+	// Self is SafeBase's own metadata database when it is backed up, the
+	// only SQLite database allowed outside SQLiteRoot. It is never restored
+	// into.
+	Self models.Database
 }
 
 func NewBackupExecutor(backupDir string) *BackupExecutor {
@@ -38,166 +73,251 @@ func NewBackupExecutor(backupDir string) *BackupExecutor {
 	return &BackupExecutor{BackupDir: backupDir}
 }
 
-func (be *BackupExecutor) ExecuteBackup(db models.Database, scheduleID string) (models.Backup, error) {
+func (be *BackupExecutor) storage(targetID string) (storage.Storage, error) {
+	if targetID == "" {
+		return storage.NewLocal(be.BackupDir), nil
+	}
+	if be.OpenStorage == nil {
+		return nil, fmt.Errorf("storage target %s is not available", targetID)
+	}
+	return be.OpenStorage(targetID)
+}
+
+// Credentials returns db with its password resolved from PasswordRef, if
+// it references a secret.
+func (be *BackupExecutor) Credentials(ctx context.Context, db models.Database) (models.Database, error) {
+	if db.PasswordRef == "" {
+		return db, nil
+	}
+	if be.Secrets == nil {
+		return db, fmt.Errorf("no secret providers configured for %s", db.PasswordRef)
+	}
+	password, err := be.Secrets.Resolve(ctx, db.PasswordRef)
+	if err != nil {
+		return db, err
+	}
+	db.Password = password
+	return db, nil
+}
+
+// connect prepares db for the dump tools: it resolves its password and
+// opens its SSH tunnel, if any. The returned func closes the tunnel.
+func (be *BackupExecutor) connect(ctx context.Context, db models.Database) (models.Database, func(), error) {
+	if err := be.ValidateSQLite(db); err != nil {
+		return db, nil, err
+	}
+	db, err := be.Credentials(ctx, db)
+	if err != nil {
+		return db, nil, err
+	}
+	return openTunnel(db)
+}
+
+// ExecuteBackup dumps db into its storage target. Cancelling ctx, or
+// exceeding opts.Timeout, kills the dump and removes the partial artifact;
+// a cancelled backup is returned with status "cancelled".
+func (be *BackupExecutor) ExecuteBackup(ctx context.Context, db models.Database, opts Options) (models.Backup, error) {
 	startTime := time.Now()
 	backup := models.Backup{
-		ID:           uuid.New().String(),
-		DatabaseID:   db.ID,
-		DatabaseName: db.Name,
-		Status:       "in_progress",
-		Type:         "scheduled",
-		CreatedAt:    time.Now(),
+		ID:              uuid.New().String(),
+		DatabaseID:      db.ID,
+		DatabaseName:    db.Name,
+		ScheduleID:      opts.ScheduleID,
+		Status:          "in_progress",
+		Type:            "scheduled",
+		StorageTargetID: opts.StorageTargetID,
+		Compression:     opts.Compression.Algorithm,
+		RawDump:         compresses(opts.Compression.Algorithm),
+		CreatedAt:       time.Now(),
+		RetryOf:         opts.RetryOf,
+		Retry:           opts.Retry,
+		WaitDuration:    int(opts.Wait.Seconds()),
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
 
-	var filePath string
-	var err error
+	progress := reporterOf(opts)
+	reportPhase(progress, PhaseConnecting)
 
-	if db.Type == "mysql" {
-		filePath, err = be.backupMySQL(db)
-	} else if db.Type == "postgresql" {
-		filePath, err = be.backupPostgreSQL(db)
-	} else {
-		err = fmt.Errorf("unsupported database type: %s", db.Type)
+	var store storage.Storage
+	var key string
+	var stats dumpStats
+	var dataKey []byte
+	driver, err := GetDriver(db.Type)
+	if err == nil {
+		var closeTunnel func()
+		db, closeTunnel, err = be.connect(ctx, db)
+		if err == nil {
+			defer closeTunnel()
+		}
+	}
+	if err == nil {
+		store, err = be.storage(opts.StorageTargetID)
+	}
+	if err == nil && be.Keyring != nil {
+		var wrapped []byte
+		dataKey, backup.EncryptionKeyID, wrapped, err = be.Keyring.NewDataKey([]byte(backup.ID))
+		backup.EncryptedDataKey = base64.StdEncoding.EncodeToString(wrapped)
+	}
+	if err == nil {
+		timestamp := time.Now().Format("20060102_150405")
+		key = fmt.Sprintf("%s_%s%s%s", db.Name, timestamp, driver.Extension(), compressionExtension(opts.Compression.Algorithm))
+		if dataKey != nil {
+			key += encryptedExtension
+		}
+		stats, err = be.upload(ctx, driver, db, store, key, opts.Compression, dataKey, progress)
+		if err == nil {
+			reportPhase(progress, PhaseVerifying)
+			err = verifyStored(ctx, store, key, stats.size)
+		}
+		if err != nil {
+			// ctx may be what failed the backup; clean up regardless
+			store.Delete(context.Background(), key)
+		}
 	}
 
 	duration := int(time.Since(startTime).Seconds())
 
 	if err != nil {
 		backup.Status = "failed"
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			backup.Status = "cancelled"
+			err = errors.New("backup cancelled")
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("backup timed out after %s", opts.Timeout)
+		}
 		backup.Error = err.Error()
 		backup.Duration = duration
 		return backup, err
 	}
 
-	fileInfo, statErr := os.Stat(filePath)
-	if statErr == nil {
-		size := fileInfo.Size()
-		if size < 1024 {
-			backup.Size = fmt.Sprintf("%d B", size)
-		} else if size < 1024*1024 {
-			backup.Size = fmt.Sprintf("%.2f KB", float64(size)/1024)
-		} else {
-			backup.Size = fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
-		}
+	backup.SizeBytes = stats.size
+	backup.Size = formatSize(stats.size)
+	if stats.size > 0 {
+		backup.CompressionRatio = float64(stats.rawSize) / float64(stats.size)
 	}
+	backup.Checksum = stats.checksum
 
-	backup.FilePath = filePath
+	backup.FilePath = store.URI(key)
 	backup.Status = "success"
-	backup.Duration = duration
+
+	if len(opts.ReplicaTargetIDs) > 0 {
+		reportPhase(progress, PhaseReplicating)
+		backup.RequiredCopies = opts.RequiredCopies
+		be.replicate(ctx, &backup, opts.ReplicaTargetIDs)
+	}
+	backup.Duration = int(time.Since(startTime).Seconds())
 
 	return backup, nil
 }
 
-func (be *BackupExecutor) backupMySQL(db models.Database) (string, error) {
-	timestamp := time.Now().Format("20060102_150405")
-	fileName := fmt.Sprintf("%s_%s.sql", db.Name, timestamp)
-	filePath := filepath.Join(be.BackupDir, fileName)
-
-	env := os.Environ()
-	env = append(env, fmt.Sprintf("MYSQL_PWD=%s", db.Password))
-
-	mysqldumpPath := findCommand("mysqldump")
-	
-	host := db.Host
-	// In Docker, use service name directly; for localhost, convert to 127.0.0.1
-	if host == "localhost" {
-		host = "127.0.0.1"
-	}
-	// Note: In Docker Compose, use service name "mysql" instead of localhost
-	
-	cmd := exec.Command(mysqldumpPath,
-		"-h", host,
-		"-P", fmt.Sprintf("%d", db.Port),
-		"-u", db.Username,
-		"--protocol=TCP",
-		"--single-transaction",
-		"--quick",
-		"--lock-tables=false",
-		db.Database,
-	)
-
-	cmd.Env = env
-
-	outputFile, err := os.Create(filePath)
-	if err != nil {
-		return "", err
+// dumpStats describes a written artifact: the uncompressed dump size, the
+// stored size and the SHA-256 of the stored bytes.
+type dumpStats struct {
+	rawSize, size int64
+	checksum      string
+}
+
+// upload pipes the dump straight into the storage target, so the artifact
+// never has to fit on the local disk first.
+func (be *BackupExecutor) upload(ctx context.Context, driver Driver, db models.Database, store storage.Storage, key string, compression Compression, dataKey []byte, progress Reporter) (dumpStats, error) {
+	pr, pw := io.Pipe()
+
+	type dumpResult struct {
+		stats dumpStats
+		err   error
 	}
-	defer outputFile.Close()
+	done := make(chan dumpResult, 1)
+	go func() {
+		stats, err := be.dump(ctx, driver, db, pw, compression, dataKey, progress)
+		pw.CloseWithError(err)
+		done <- dumpResult{stats, err}
+	}()
 
-	cmd.Stdout = outputFile
+	putErr := store.Put(ctx, key, pr)
+	// Unblock the dump if the upload stopped reading early
+	pr.CloseWithError(putErr)
+	result := <-done
 
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+	if result.err != nil {
+		return dumpStats{}, result.err
+	}
+	if putErr != nil {
+		return dumpStats{}, fmt.Errorf("failed to store backup: %v", putErr)
+	}
+	return result.stats, nil
+}
 
-	err = cmd.Run()
+// dump streams the driver output through the compressor, and the encryptor
+// when dataKey is set, into w, hashing the stored bytes as they are written.
+func (be *BackupExecutor) dump(ctx context.Context, driver Driver, db models.Database, w io.Writer, compression Compression, dataKey []byte, progress Reporter) (dumpStats, error) {
+	hash := sha256.New()
+	written := &countingWriter{w: io.MultiWriter(w, hash)}
+
+	var encryptor io.WriteCloser = nopWriteCloser{written}
+	if dataKey != nil {
+		var err error
+		encryptor, err = newEncryptWriter(written, dataKey)
+		if err != nil {
+			return dumpStats{}, err
+		}
+	}
+
+	compressor, err := newCompressWriter(encryptor, compression)
 	if err != nil {
-		os.Remove(filePath)
-		return "", fmt.Errorf("mysqldump failed: %v, stderr: %s", err, stderr.String())
+		return dumpStats{}, err
 	}
+	raw := &countingWriter{w: compressor}
 
-	return filePath, nil
-}
+	// Drivers whose tools can compress their output, like mongodump, leave
+	// it to the compressor when there is one
+	db.Compression, db.CompressionLevel = compression.Algorithm, compression.Level
 
-func (be *BackupExecutor) backupPostgreSQL(db models.Database) (string, error) {
-	timestamp := time.Now().Format("20060102_150405")
-	fileName := fmt.Sprintf("%s_%s.dump", db.Name, timestamp)
-	filePath := filepath.Join(be.BackupDir, fileName)
-
-	var cmd *exec.Cmd
-	var stderr bytes.Buffer
-
-	// Check if we're in Docker and host is localhost - try docker exec first
-	// Otherwise, use pg_dump directly (works for Docker service names and external hosts)
-	if db.Host == "localhost" || db.Host == "127.0.0.1" {
-		// Try docker exec first (for local development outside Docker)
-		containerPath := "/tmp/" + fileName
-		dockerCmd := exec.Command("docker", "exec",
-			"-e", fmt.Sprintf("PGPASSWORD=%s", db.Password),
-			"safebase-postgres",
-			"pg_dump",
-			"-U", db.Username,
-			"-d", db.Database,
-			"-F", "c",
-			"-f", containerPath,
-		)
-		dockerCmd.Stderr = &stderr
-		
-		if err := dockerCmd.Run(); err == nil {
-			// Docker exec succeeded, copy the file
-			cpCmd := exec.Command("docker", "cp", "safebase-postgres:"+containerPath, filePath)
-			if err := cpCmd.Run(); err != nil {
-				return "", fmt.Errorf("failed to copy backup from container: %v", err)
-			}
-			exec.Command("docker", "exec", "safebase-postgres", "rm", containerPath).Run()
-			return filePath, nil
-		}
-		// Docker exec failed, fall through to use pg_dump directly
-	}
-
-	// Use pg_dump directly (works in Docker with service names like "postgresql" or external hosts)
-	pgDumpPath := findCommand("pg_dump")
-	host := db.Host
-	if host == "localhost" {
-		host = "127.0.0.1"
-	}
-	
-	cmd = exec.Command(pgDumpPath,
-		"-h", host,
-		"-p", fmt.Sprintf("%d", db.Port),
-		"-U", db.Username,
-		"-d", db.Database,
-		"-F", "c",
-		"-f", filePath,
-	)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", db.Password))
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	bytesReporter := &byteReporter{r: progress, raw: raw, written: written}
+	stderr := newLineWriter(progress)
+	err = driver.Backup(ctx, db, tickWriter{w: raw, tick: bytesReporter.tick}, stderr)
+	stderr.Flush()
 	if err != nil {
-		os.Remove(filePath)
-		return "", fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderr.String())
+		compressor.Close()
+		return dumpStats{}, err
 	}
 
-	return filePath, nil
+	reportPhase(progress, PhaseCompressing)
+	if err := compressor.Close(); err != nil {
+		return dumpStats{}, err
+	}
+	if err := encryptor.Close(); err != nil {
+		return dumpStats{}, err
+	}
+	bytesReporter.flush()
+	reportPhase(progress, PhaseUploading)
+
+	return dumpStats{rawSize: raw.n, size: written.n, checksum: hex.EncodeToString(hash.Sum(nil))}, nil
 }
 
+// verifyStored checks that the storage holds every byte that was written,
+// catching uploads cut short without an error.
+func verifyStored(ctx context.Context, store storage.Storage, key string, size int64) error {
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to check stored backup: %v", err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("stored backup is %d bytes, expected %d", info.Size, size)
+	}
+	return nil
+}
+
+func formatSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	} else if size < 1024*1024 {
+		return fmt.Sprintf("%.2f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package backup
+
+import "os/exec"
+
+// killProcessTree keeps the default behaviour of killing only the tool
+// itself on platforms without process groups.
+func killProcessTree(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package backup
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessTree runs cmd in its own process group and, on cancellation,
+// kills the whole group so helpers spawned by the dump tool die with it.
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommandContextKillsProcessTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout: if only sh were killed, Run
+	// would wait for it until commandWaitDelay
+	cmd := commandContext(ctx, "sh", "-c", "sleep 30 & wait")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected the cancelled command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > commandWaitDelay/2 {
+		t.Errorf("Expected the process tree to be killed promptly, took %s", elapsed)
+	}
+}
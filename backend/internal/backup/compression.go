@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// Compression selects how dump output is compressed on its way to disk.
+// A zero Level picks the algorithm's default.
+type Compression struct {
+	Algorithm string
+	Level     int
+}
+
+func ValidateCompression(algorithm string, level int) error {
+	switch algorithm {
+	case "", CompressionNone:
+		return nil
+	case CompressionGzip:
+		if level != 0 && (level < gzip.BestSpeed || level > gzip.BestCompression) {
+			return fmt.Errorf("gzip level must be between %d and %d", gzip.BestSpeed, gzip.BestCompression)
+		}
+		return nil
+	case CompressionZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("zstd level must be between 1 and 22")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported compression: %s", algorithm)
+	}
+}
+
+// compresses reports whether algorithm actually compresses.
+func compresses(algorithm string) bool {
+	return algorithm != "" && algorithm != CompressionNone
+}
+
+func compressionExtension(algorithm string) string {
+	switch algorithm {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressWriter wraps w so that everything written is compressed. Close
+// flushes the compressor but leaves w open.
+func newCompressWriter(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c.Algorithm {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionZstd:
+		level := zstd.SpeedDefault
+		if c.Level != 0 {
+			level = zstd.EncoderLevelFromZstd(c.Level)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", c.Algorithm)
+	}
+}
+
+func newDecompressReader(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case "", CompressionNone:
+		return io.NopCloser(r), nil
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", algorithm)
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
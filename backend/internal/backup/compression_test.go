@@ -0,0 +1,59 @@
+package backup
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	input := strings.Repeat("INSERT INTO items VALUES (1, 'safebase');\n", 1000)
+
+	for _, c := range []Compression{
+		{Algorithm: CompressionNone},
+		{Algorithm: CompressionGzip, Level: 9},
+		{Algorithm: CompressionZstd, Level: 3},
+	} {
+		var buf bytes.Buffer
+		w, err := newCompressWriter(&buf, c)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Algorithm, err)
+		}
+		if _, err := io.WriteString(w, input); err != nil {
+			t.Fatalf("%s: %v", c.Algorithm, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: %v", c.Algorithm, err)
+		}
+
+		if c.Algorithm != CompressionNone && buf.Len() >= len(input) {
+			t.Errorf("%s: expected compressed output, got %d bytes for %d input", c.Algorithm, buf.Len(), len(input))
+		}
+
+		r, err := newDecompressReader(&buf, c.Algorithm)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Algorithm, err)
+		}
+		output, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.Algorithm, err)
+		}
+		if string(output) != input {
+			t.Errorf("%s: round trip mismatch", c.Algorithm)
+		}
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	if err := ValidateCompression("brotli", 0); err == nil {
+		t.Error("Expected error for unsupported compression")
+	}
+	if err := ValidateCompression(CompressionGzip, 12); err == nil {
+		t.Error("Expected error for out of range gzip level")
+	}
+	if err := ValidateCompression(CompressionZstd, 19); err != nil {
+		t.Errorf("Expected zstd level 19 to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// credentialFile writes the credentials file of a client tool, readable by
+// the server user only, in a private temporary directory. The returned
+// cleanup func removes the directory; call it once the tool has exited.
+// Passwords handed over this way show up neither in argv nor in the
+// environment of the tool, both of which other local users can read.
+func credentialFile(name, content string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "safebase-credentials-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// pgpassEntry is a .pgpass line giving password for any host, port,
+// database and user: the file is written for a single command.
+func pgpassEntry(password string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `:`, `\:`).Replace(password)
+	return "*:*:*:*:" + escaped + "\n"
+}
+
+// mysqlOptionFile is a MySQL option file holding the client password.
+func mysqlOptionFile(password string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(password)
+	return "[client]\npassword=\"" + escaped + "\"\n"
+}
+
+// copyToContainer copies a file into a running container. It is a variable
+// so tests can check docker exec command lines without Docker.
+var copyToContainer = func(src, container, dst string) error {
+	out, err := exec.Command("docker", "cp", src, container+":"+dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker cp failed: %v, output: %s", err, out)
+	}
+	return nil
+}
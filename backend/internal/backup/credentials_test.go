@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+const testSecret = `s3cr:et"\pw`
+
+// credentialArg returns the value of the argument or environment variable
+// named by prefix, such as --config= or PGPASSFILE=.
+func credentialArg(cmd *exec.Cmd, prefix string) string {
+	for _, arg := range append(append([]string{}, cmd.Args...), cmd.Env...) {
+		if value, ok := strings.CutPrefix(arg, prefix); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// assertNoSecret checks that the secret is neither in argv nor in the
+// environment of cmd, and that it is in a private credentials file which
+// cleanup removes.
+func assertNoSecret(t *testing.T, cmd *exec.Cmd, cleanup func(), prefix string) {
+	t.Helper()
+	for _, arg := range append(append([]string{}, cmd.Args...), cmd.Env...) {
+		if strings.Contains(arg, "s3cr") {
+			t.Errorf("Secret exposed in %q", arg)
+		}
+	}
+
+	path := credentialArg(cmd, prefix)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected a credentials file passed with %s: %v", prefix, err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the credentials file to be 0600, got %v", info.Mode().Perm())
+	}
+	if dir, _ := os.Stat(filepath.Dir(path)); dir.Mode().Perm() != 0o700 {
+		t.Errorf("Expected a private directory, got %v", dir.Mode().Perm())
+	}
+
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected cleanup to remove the credentials, got %v", err)
+	}
+}
+
+func TestCommandsKeepSecretOutOfArgv(t *testing.T) {
+	db := models.Database{Host: "db.internal", Port: 5432, Username: "app", Password: testSecret, Database: "shop"}
+	ctx := context.Background()
+
+	cmd, cleanup, err := postgresDriver{}.command(ctx, db, "pg_dump", "-d", db.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(credentialArg(cmd, "PGPASSFILE="))
+	if string(content) != `*:*:*:*:s3cr\:et"\\pw`+"\n" {
+		t.Errorf("Unexpected .pgpass entry %q", content)
+	}
+	assertNoSecret(t, cmd, cleanup, "PGPASSFILE=")
+
+	cmd, cleanup, err = mysqlDriver{}.command(ctx, db, "mysqldump", db.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(cmd.Args[1], "--defaults-extra-file=") {
+		t.Errorf("Expected --defaults-extra-file first, got %v", cmd.Args)
+	}
+	content, _ = os.ReadFile(credentialArg(cmd, "--defaults-extra-file="))
+	if string(content) != "[client]\npassword=\"s3cr:et\\\"\\\\pw\"\n" {
+		t.Errorf("Unexpected option file %q", content)
+	}
+	assertNoSecret(t, cmd, cleanup, "--defaults-extra-file=")
+
+	db.ConnectionURI = "mongodb://app:" + testSecret + "@db.internal/shop"
+	cmd, cleanup, err = mongoDriver{}.command(ctx, db, "mongodump", "--archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoSecret(t, cmd, cleanup, "--config=")
+}
+
+func TestContainerCommandKeepsSecretOutOfArgv(t *testing.T) {
+	var copied, copiedTo string
+	defer func(original func(src, container, dst string) error) { copyToContainer = original }(copyToContainer)
+	copyToContainer = func(src, container, dst string) error {
+		content, err := os.ReadFile(src)
+		copied, copiedTo = string(content), dst
+		return err
+	}
+
+	db := models.Database{Host: "localhost", Port: 5432, Username: "app", Password: testSecret, Database: "shop"}
+	cmd, cleanup, err := postgresDriver{}.containerCommand(context.Background(), db, "pg_dump", "-d", db.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, "s3cr") {
+			t.Errorf("Secret exposed in %q", arg)
+		}
+	}
+	if !strings.Contains(copied, "s3cr") || credentialArg(cmd, "PGPASSFILE=") != copiedTo {
+		t.Errorf("Expected the .pgpass copied into the container at PGPASSFILE, got %q at %s, args %v", copied, copiedTo, cmd.Args)
+	}
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"safebase-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduleTypeBackup      = "backup"
+	ScheduleTypeRestoreTest = "restore_test"
+)
+
+// Sandbox is implemented by drivers that support restore drills: they can
+// create a throwaway database on a server, query it and drop it again.
+type Sandbox interface {
+	// CreateSandbox creates the scratch database name on server and returns
+	// the connection settings to reach it.
+	CreateSandbox(server models.Database, name string) (models.Database, error)
+	DropSandbox(sandbox models.Database) error
+	// QueryValue runs query and returns the first column of its first row.
+	QueryValue(ctx context.Context, sandbox models.Database, query string) (string, bool, error)
+}
+
+func sandboxFor(dbType string) (Sandbox, error) {
+	driver, err := GetDriver(dbType)
+	if err != nil {
+		return nil, err
+	}
+	sandbox, ok := driver.(Sandbox)
+	if !ok {
+		return nil, fmt.Errorf("restore drills are not supported for %s databases", dbType)
+	}
+	return sandbox, nil
+}
+
+// ValidateDrill checks that server can host restore drills of source.
+func ValidateDrill(source, server models.Database) error {
+	if server.ID == source.ID {
+		return fmt.Errorf("the sandbox server must not be the database under test")
+	}
+	if server.Type != source.Type {
+		return fmt.Errorf("sandbox server is %s but the database is %s", server.Type, source.Type)
+	}
+	_, err := sandboxFor(server.Type)
+	return err
+}
+
+// ExecuteDrill restores backup into a scratch database on server, runs the
+// sanity checks against it and drops it again. The drill fails when the
+// restore or any check fails; the returned error only reports problems
+// setting up the sandbox. Cancelling ctx stops the restore and the checks.
+func (be *BackupExecutor) ExecuteDrill(ctx context.Context, backup models.Backup, server models.Database, checks []models.SanityCheck) (models.RestoreDrill, error) {
+	startTime := time.Now()
+	drill := models.RestoreDrill{
+		ID:                uuid.New().String(),
+		DatabaseID:        backup.DatabaseID,
+		DatabaseName:      backup.DatabaseName,
+		BackupID:          backup.ID,
+		SandboxDatabaseID: server.ID,
+		Status:            "failed",
+		Checks:            []models.SanityCheckResult{},
+		CreatedAt:         time.Now(),
+	}
+
+	sandbox, err := sandboxFor(server.Type)
+	if err == nil {
+		var closeTunnel func()
+		server, closeTunnel, err = be.connect(ctx, server)
+		if err == nil {
+			defer closeTunnel()
+		}
+	}
+	if err != nil {
+		drill.Error = err.Error()
+		return drill, err
+	}
+
+	name := "safebase_drill_" + strings.ReplaceAll(drill.ID, "-", "")[:12]
+	target, err := sandbox.CreateSandbox(server, name)
+	if err != nil {
+		drill.Error = fmt.Sprintf("failed to create sandbox: %v", err)
+		drill.Duration = int(time.Since(startTime).Seconds())
+		return drill, err
+	}
+	defer func() {
+		if err := sandbox.DropSandbox(target); err != nil {
+			log.Printf("Failed to drop drill sandbox %s: %v", name, err)
+		}
+	}()
+
+	// The sandbox is reached through the tunnel opened for server above
+	restoreStart := time.Now()
+	err = be.restore(ctx, backup, target)
+	drill.RestoreDuration = int(time.Since(restoreStart).Seconds())
+	if err != nil {
+		drill.Error = fmt.Sprintf("restore failed: %v", err)
+		drill.Duration = int(time.Since(startTime).Seconds())
+		return drill, nil
+	}
+
+	passed := true
+	for _, check := range checks {
+		result := runSanityCheck(ctx, sandbox, target, check)
+		passed = passed && result.Passed
+		drill.Checks = append(drill.Checks, result)
+	}
+
+	if passed {
+		drill.Status = "passed"
+	} else {
+		drill.Error = "sanity checks failed"
+	}
+	drill.Duration = int(time.Since(startTime).Seconds())
+	return drill, nil
+}
+
+func runSanityCheck(ctx context.Context, sandbox Sandbox, target models.Database, check models.SanityCheck) models.SanityCheckResult {
+	result := models.SanityCheckResult{SanityCheck: check}
+
+	value, found, err := sandbox.QueryValue(ctx, target, check.Query)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Value = value
+
+	switch {
+	case check.Expect != "":
+		result.Passed = value == check.Expect
+	case check.Min != nil:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			result.Error = fmt.Sprintf("expected a number, got %q", value)
+			return result
+		}
+		result.Passed = n >= *check.Min
+	default:
+		result.Passed = found
+	}
+	return result
+}
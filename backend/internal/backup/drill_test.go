@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestExecuteDrill(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "source.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE canary (name TEXT); INSERT INTO canary VALUES ('alive'), ('well')"); err != nil {
+		t.Fatal(err)
+	}
+
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = dir
+	db := models.Database{ID: "db1", Name: "canary", Type: "sqlite", Host: dbPath}
+	b, err := be.ExecuteBackup(context.Background(), db, OptionsFor(db, nil))
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+
+	sandboxDir := filepath.Join(dir, "sandbox")
+	server := models.Database{ID: "sandbox", Name: "sandbox", Type: "sqlite", Host: sandboxDir}
+	if err := ValidateDrill(db, server); err != nil {
+		t.Fatalf("ValidateDrill failed: %v", err)
+	}
+
+	two := int64(2)
+	drill, err := be.ExecuteDrill(context.Background(), b, server, []models.SanityCheck{
+		{Query: "SELECT count(*) FROM canary", Min: &two},
+		{Query: "SELECT name FROM canary ORDER BY name", Expect: "alive"},
+		{Query: "SELECT 1 FROM canary WHERE name = 'well'"},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteDrill failed: %v", err)
+	}
+	if drill.Status != "passed" {
+		t.Errorf("Expected drill to pass, got %s: %s %+v", drill.Status, drill.Error, drill.Checks)
+	}
+
+	drill, err = be.ExecuteDrill(context.Background(), b, server, []models.SanityCheck{
+		{Query: "SELECT count(*) FROM canary", Expect: "3"},
+		{Query: "SELECT * FROM missing"},
+	})
+	if err != nil {
+		t.Fatalf("ExecuteDrill failed: %v", err)
+	}
+	if drill.Status != "failed" || drill.Checks[0].Value != "2" || drill.Checks[1].Error == "" {
+		t.Errorf("Expected failed checks, got %+v", drill.Checks)
+	}
+
+	entries, err := os.ReadDir(sandboxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected sandboxes to be dropped, found %d files", len(entries))
+	}
+}
+
+func TestValidateDrillRejectsSourceAsSandbox(t *testing.T) {
+	db := models.Database{ID: "db1", Type: "sqlite"}
+	if err := ValidateDrill(db, db); err == nil {
+		t.Error("Expected the database under test to be rejected as sandbox")
+	}
+	if err := ValidateDrill(db, models.Database{ID: "db2", Type: "mysql"}); err == nil {
+		t.Error("Expected a sandbox of another type to be rejected")
+	}
+}
+
+// lineDriver talks to a server echoing lines back, and records the address
+// each call was given.
+type lineDriver struct {
+	mu    sync.Mutex
+	addrs map[string]string
+}
+
+func init() {
+	RegisterDriver("linetest", &lineDriver{addrs: make(map[string]string)})
+}
+
+func (d *lineDriver) send(call string, db models.Database, line string) (string, error) {
+	addr := net.JoinHostPort(db.Host, strconv.Itoa(db.Port))
+	d.mu.Lock()
+	d.addrs[call] = addr
+	d.mu.Unlock()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, line)
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	return strings.TrimSuffix(reply, "\n"), err
+}
+
+func (d *lineDriver) Extension() string { return ".lines" }
+
+func (d *lineDriver) Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error {
+	reply, err := d.send("backup", db, "dump")
+	if err == nil {
+		_, err = fmt.Fprintln(w, reply)
+	}
+	return err
+}
+
+func (d *lineDriver) Restore(ctx context.Context, db models.Database, r io.Reader) error {
+	_, err := d.send("restore", db, "restore")
+	return err
+}
+
+func (d *lineDriver) TestConnection(db models.Database) error {
+	_, err := d.send("test", db, "ping")
+	return err
+}
+
+func (d *lineDriver) ListDatabases(db models.Database) ([]string, error) { return nil, nil }
+
+func (d *lineDriver) EstimateSize(db models.Database) (int64, error) { return 0, nil }
+
+func (d *lineDriver) CreateSandbox(server models.Database, name string) (models.Database, error) {
+	_, err := d.send("create", server, "create "+name)
+	sandbox := server
+	sandbox.Database = name
+	return sandbox, err
+}
+
+func (d *lineDriver) DropSandbox(sandbox models.Database) error {
+	_, err := d.send("drop", sandbox, "drop")
+	return err
+}
+
+func (d *lineDriver) QueryValue(ctx context.Context, sandbox models.Database, query string) (string, bool, error) {
+	reply, err := d.send("query", sandbox, query)
+	return reply, err == nil, err
+}
+
+func TestExecuteDrillThroughBastion(t *testing.T) {
+	hostKey, _ := newSigner(t)
+	clientKey, clientPEM := newSigner(t)
+	bastion := startBastion(t, hostKey, clientKey.PublicKey())
+
+	// The database server is only reachable by the bastion, as localhost
+	dbAddr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	})
+	_, dbPort, _ := net.SplitHostPort(dbAddr)
+	port, _ := strconv.Atoi(dbPort)
+	tunnel := models.Database{
+		Type:          "linetest",
+		Host:          "localhost",
+		Port:          port,
+		SSHHost:       bastion,
+		SSHUser:       "backup",
+		SSHPrivateKey: string(clientPEM),
+		SSHKnownHosts: knownhosts.Line([]string{bastion}, hostKey.PublicKey()),
+	}
+
+	be := NewBackupExecutor(t.TempDir())
+	db := tunnel
+	db.ID, db.Name = "db1", "shop"
+	b, err := be.ExecuteBackup(context.Background(), db, OptionsFor(db, nil))
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+
+	server := tunnel
+	server.ID, server.Name = "sandbox", "sandbox"
+	drill, err := be.ExecuteDrill(context.Background(), b, server, []models.SanityCheck{{Query: "42", Expect: "42"}})
+	if err != nil {
+		t.Fatalf("ExecuteDrill failed: %v", err)
+	}
+	if drill.Status != "passed" {
+		t.Fatalf("Expected the drill to pass through the bastion, got %s: %s %+v", drill.Status, drill.Error, drill.Checks)
+	}
+
+	driver, _ := GetDriver("linetest")
+	addrs := driver.(*lineDriver).addrs
+	for _, call := range []string{"restore", "query", "drop"} {
+		if addrs[call] != addrs["create"] {
+			t.Errorf("Expected %s through the sandbox server's tunnel %s, got %s", call, addrs["create"], addrs[call])
+		}
+	}
+}
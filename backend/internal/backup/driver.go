@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Driver implements backup and restore for one database engine. Drivers
+// register themselves by type name in an init function so the executor and
+// the API never need to know which engines exist.
+type Driver interface {
+	// Extension is the file extension of the artifacts written by Backup,
+	// before any compression suffix.
+	Extension() string
+	// Backup streams a dump of db to w until done or ctx is cancelled.
+	// Diagnostic output of the dump tools, such as their stderr, is copied
+	// to log.
+	Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error
+	// Restore replays a dump produced by Backup, read from r, into db until
+	// done or ctx is cancelled.
+	Restore(ctx context.Context, db models.Database, r io.Reader) error
+	TestConnection(db models.Database) error
+	ListDatabases(db models.Database) ([]string, error)
+	// EstimateSize returns the on-disk size of db.Database in bytes.
+	EstimateSize(db models.Database) (int64, error)
+}
+
+// Verifier is implemented by drivers that can check the structure of one
+// of their dumps without a database server, to catch truncated artifacts.
+type Verifier interface {
+	Verify(r io.Reader) error
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+func RegisterDriver(dbType string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if _, exists := drivers[dbType]; exists {
+		panic("backup: driver registered twice for type " + dbType)
+	}
+	drivers[dbType] = driver
+}
+
+func GetDriver(dbType string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	driver, ok := drivers[dbType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return driver, nil
+}
+
+// driverForArtifact finds the driver that wrote an artifact from its file
+// name, once the encryption and compression suffixes are removed.
+func driverForArtifact(name string) (Driver, error) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	for _, driver := range drivers {
+		if strings.HasSuffix(name, driver.Extension()) {
+			return driver, nil
+		}
+	}
+	return nil, fmt.Errorf("no driver writes %s files", filepath.Ext(name))
+}
+
+func DriverTypes() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	types := make([]string, 0, len(drivers))
+	for dbType := range drivers {
+		types = append(types, dbType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func connectHost(db models.Database) string {
+	if db.Host == "localhost" {
+		return "127.0.0.1"
+	}
+	return db.Host
+}
@@ -0,0 +1,17 @@
+package backup
+
+import "testing"
+
+func TestBuiltinDriversRegistered(t *testing.T) {
+	for _, dbType := range []string{"mysql", "postgresql", "mongodb"} {
+		if _, err := GetDriver(dbType); err != nil {
+			t.Errorf("Expected driver for '%s', got error: %v", dbType, err)
+		}
+	}
+}
+
+func TestGetDriverUnsupported(t *testing.T) {
+	if _, err := GetDriver("oracle"); err == nil {
+		t.Error("Expected error for unsupported database type")
+	}
+}
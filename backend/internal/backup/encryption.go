@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted artifacts are a small header followed by AES-256-GCM chunks:
+//
+//	header: "SBENC1" | nonce prefix (7 bytes)
+//	chunk:  final flag (1 byte) | ciphertext length (4 bytes) | ciphertext
+//
+// Each chunk nonce is the prefix, a 4-byte counter and the final flag, so
+// chunks cannot be reordered, and a stream cut before its final chunk is
+// rejected instead of being restored as a shorter dump.
+const (
+	encryptedExtension  = ".enc"
+	encryptionMagic     = "SBENC1"
+	encryptionChunkSize = 64 * 1024
+	noncePrefixSize     = 7
+)
+
+func newChunkCipher(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// newEncryptWriter encrypts everything written to it with dataKey. Close
+// writes the final chunk but leaves w open.
+func newEncryptWriter(w io.Writer, dataKey []byte) (io.WriteCloser, error) {
+	gcm, err := newChunkCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(encryptionMagic), prefix...)); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:      w,
+		gcm:    gcm,
+		prefix: prefix,
+		buf:    make([]byte, 0, encryptionChunkSize),
+	}, nil
+}
+
+func (ew *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+
+		if len(ew.buf) == cap(ew.buf) {
+			if err := ew.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (ew *encryptWriter) Close() error {
+	return ew.flush(true)
+}
+
+func (ew *encryptWriter) flush(final bool) error {
+	ciphertext := ew.gcm.Seal(nil, chunkNonce(ew.prefix, ew.counter, final), ew.buf, nil)
+	ew.counter++
+	ew.buf = ew.buf[:0]
+
+	header := make([]byte, 5)
+	if final {
+		header[0] = 1
+	}
+	binary.BigEndian.PutUint32(header[1:], uint32(len(ciphertext)))
+
+	if _, err := ew.w.Write(header); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(ciphertext)
+	return err
+}
+
+type decryptReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     bytes.Buffer
+	done    bool
+}
+
+func newDecryptReader(r io.Reader, dataKey []byte) (io.Reader, error) {
+	gcm, err := newChunkCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(encryptionMagic)+noncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read encryption header: %v", err)
+	}
+	if string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, errors.New("backup file is not encrypted by SafeBase")
+	}
+
+	return &decryptReader{r: r, gcm: gcm, prefix: header[len(encryptionMagic):]}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for dr.buf.Len() == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	return dr.buf.Read(p)
+}
+
+func (dr *decryptReader) readChunk() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(dr.r, header); err != nil {
+		if err == io.EOF {
+			return errors.New("encrypted backup is truncated")
+		}
+		return err
+	}
+
+	final := header[0] == 1
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > encryptionChunkSize+uint32(dr.gcm.Overhead()) {
+		return errors.New("encrypted backup is corrupted: chunk too large")
+	}
+
+	ciphertext := make([]byte, length)
+	if _, err := io.ReadFull(dr.r, ciphertext); err != nil {
+		return fmt.Errorf("encrypted backup is truncated: %v", err)
+	}
+
+	plaintext, err := dr.gcm.Open(nil, chunkNonce(dr.prefix, dr.counter, final), ciphertext, nil)
+	if err != nil {
+		return errors.New("encrypted backup is corrupted: authentication failed")
+	}
+	dr.counter++
+	dr.buf.Write(plaintext)
+
+	if final {
+		dr.done = true
+		if n, _ := dr.r.Read(make([]byte, 1)); n > 0 {
+			return errors.New("encrypted backup is corrupted: data after final chunk")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+func encryptForTest(t *testing.T, dataKey, plaintext []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := newEncryptWriter(&buf, dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	for _, size := range []int{0, 10, encryptionChunkSize, 3*encryptionChunkSize + 17} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+
+		r, err := newDecryptReader(bytes.NewReader(encryptForTest(t, dataKey, plaintext)), dataKey)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		output, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(output, plaintext) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTruncatedStream(t *testing.T) {
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	plaintext := make([]byte, 2*encryptionChunkSize+100)
+	ciphertext := encryptForTest(t, dataKey, plaintext)
+
+	// Drop the final chunk: the remaining chunks still authenticate
+	truncated := ciphertext[:len(encryptionMagic)+noncePrefixSize+2*(5+encryptionChunkSize+16)]
+
+	r, err := newDecryptReader(bytes.NewReader(truncated), dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("Expected error for truncated stream")
+	}
+}
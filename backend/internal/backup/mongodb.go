@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"safebase-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const mongoArchiveMagic = 0x8199e26d
+
+type mongoDriver struct{}
+
+func init() {
+	RegisterDriver("mongodb", mongoDriver{})
+}
+
+func (mongoDriver) Extension() string {
+	return ".archive"
+}
+
+// command builds a mongodump/mongorestore invocation. The password, and the
+// connection URI which may embed one, are written to a private YAML file
+// passed through --config; the returned cleanup func removes that file.
+func (mongoDriver) command(ctx context.Context, db models.Database, name string, args ...string) (*exec.Cmd, func(), error) {
+	var connArgs []string
+	var config strings.Builder
+	if db.ConnectionURI != "" {
+		fmt.Fprintf(&config, "uri: %s\n", strconv.Quote(db.ConnectionURI))
+	} else {
+		connArgs = append(connArgs,
+			"--host="+connectHost(db),
+			fmt.Sprintf("--port=%d", db.Port),
+		)
+	}
+	if db.AuthDatabase != "" {
+		connArgs = append(connArgs, "--authenticationDatabase="+db.AuthDatabase)
+	}
+	if db.Username != "" {
+		connArgs = append(connArgs, "--username="+db.Username)
+		fmt.Fprintf(&config, "password: %s\n", strconv.Quote(db.Password))
+	}
+
+	cleanup := func() {}
+	if config.Len() > 0 {
+		configFile, removeConfig, err := credentialFile("mongo.yaml", config.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		cleanup = removeConfig
+		connArgs = append(connArgs, "--config="+configFile)
+	}
+
+	return commandContext(ctx, findCommand(name), append(connArgs, args...)...), cleanup, nil
+}
+
+func (mongoDriver) connect(db models.Database) (*mongo.Client, error) {
+	uri := db.ConnectionURI
+	if uri == "" {
+		uri = fmt.Sprintf("mongodb://%s:%d", connectHost(db), db.Port)
+	}
+
+	opts := options.Client().ApplyURI(uri).SetServerSelectionTimeout(10 * time.Second)
+	if db.Username != "" {
+		opts.SetAuth(options.Credential{
+			Username:   db.Username,
+			Password:   db.Password,
+			AuthSource: db.AuthDatabase,
+		})
+	}
+
+	return mongo.Connect(opts)
+}
+
+// gzipArgs has mongodump compress the archive content, unless SafeBase
+// compresses the whole artifact, given by db.Compression; mongorestore must
+// then be told the same.
+func gzipArgs(db models.Database) []string {
+	if compresses(db.Compression) {
+		return nil
+	}
+	return []string{"--gzip"}
+}
+
+func (d mongoDriver) Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error {
+	args := append([]string{"--archive"}, gzipArgs(db)...)
+	if db.Database != "" {
+		args = append(args, "--db="+db.Database)
+	}
+	if db.ReadPreference != "" {
+		args = append(args, "--readPreference="+db.ReadPreference)
+	}
+
+	cmd, cleanup, err := d.command(ctx, db, "mongodump", args...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = w
+
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(&stderr, log)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mongodump failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func (d mongoDriver) Restore(ctx context.Context, db models.Database, r io.Reader) error {
+	args := append([]string{"--archive", "--drop"}, gzipArgs(db)...)
+	if db.Database != "" {
+		// Archives hold a single database; rename it so a backup can be
+		// restored into a target with a different database name.
+		args = append(args, "--nsFrom=$db$.$coll$", "--nsTo="+db.Database+".$coll$")
+	}
+
+	cmd, cleanup, err := d.command(ctx, db, "mongorestore", args...)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdin = r
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mongorestore failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+// Verify checks the magic number mongodump writes at the start of archives.
+func (mongoDriver) Verify(r io.Reader) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("archive is too short: %v", err)
+	}
+	if binary.LittleEndian.Uint32(header) != mongoArchiveMagic {
+		return errors.New("not a mongodump archive")
+	}
+	return nil
+}
+
+func (d mongoDriver) TestConnection(db models.Database) error {
+	client, err := d.connect(db)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	defer client.Disconnect(ctx)
+
+	return client.Ping(ctx, nil)
+}
+
+func (d mongoDriver) ListDatabases(db models.Database) ([]string, error) {
+	client, err := d.connect(db)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	defer client.Disconnect(ctx)
+
+	return client.ListDatabaseNames(ctx, bson.D{})
+}
+
+func (d mongoDriver) EstimateSize(db models.Database) (int64, error) {
+	client, err := d.connect(db)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	defer client.Disconnect(ctx)
+
+	var stats struct {
+		StorageSize float64 `bson:"storageSize"`
+		IndexSize   float64 `bson:"indexSize"`
+	}
+	err = client.Database(db.Database).RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}).Decode(&stats)
+	if err != nil {
+		return 0, err
+	}
+	return int64(stats.StorageSize + stats.IndexSize), nil
+}
+
+func (d mongoDriver) ServerVersion(db models.Database) (string, error) {
+	client, err := d.connect(db)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	defer client.Disconnect(ctx)
+
+	var info struct {
+		Version string `bson:"version"`
+	}
+	err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info)
+	return info.Version, err
+}
+
+// CheckPermissions checks that mongodump is installed and that the user may
+// list what it would dump: the collections of db.Database, or every
+// database when none is set.
+func (d mongoDriver) CheckPermissions(db models.Database) []PermissionCheck {
+	_, err := exec.LookPath(findCommand("mongodump"))
+	checks := []PermissionCheck{checkOf("mongodump installed", err)}
+
+	client, err := d.connect(db)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+		defer client.Disconnect(ctx)
+
+		if db.Database != "" {
+			_, err = client.Database(db.Database).ListCollectionNames(ctx, bson.D{})
+		} else {
+			_, err = client.ListDatabaseNames(ctx, bson.D{})
+		}
+	}
+	return append(checks, checkOf("list collections", err))
+}
@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"safebase-backend/internal/models"
+	"strconv"
+	"strings"
+)
+
+// mysqldump ends every complete dump with "-- Dump completed on <date>"
+const (
+	mysqlDumpTrailer   = "-- Dump completed"
+	mysqlTrailerWindow = 1024
+)
+
+type mysqlDriver struct{}
+
+func init() {
+	RegisterDriver("mysql", mysqlDriver{})
+}
+
+func (mysqlDriver) Extension() string {
+	return ".sql"
+}
+
+func (mysqlDriver) connArgs(db models.Database) []string {
+	return []string{
+		"-h", connectHost(db),
+		"-P", fmt.Sprintf("%d", db.Port),
+		"-u", db.Username,
+		"--protocol=TCP",
+	}
+}
+
+// command builds a mysql/mysqldump invocation. The password is written to a
+// private option file passed through --defaults-extra-file, which must come
+// first; the returned cleanup func removes that file.
+func (d mysqlDriver) command(ctx context.Context, db models.Database, name string, args ...string) (*exec.Cmd, func(), error) {
+	optionFile, cleanup, err := credentialFile("my.cnf", mysqlOptionFile(db.Password))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmdArgs := append([]string{"--defaults-extra-file=" + optionFile}, d.connArgs(db)...)
+	return commandContext(ctx, findCommand(name), append(cmdArgs, args...)...), cleanup, nil
+}
+
+func (d mysqlDriver) query(db models.Database, sql string) (string, error) {
+	cmd, cleanup, err := d.command(context.Background(), db, "mysql", "-N", "-B", "-e", sql)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("mysql query failed: %v, stderr: %s", err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (d mysqlDriver) Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error {
+	cmd, cleanup, err := d.command(ctx, db, "mysqldump",
+		"--single-transaction",
+		"--quick",
+		"--lock-tables=false",
+		db.Database,
+	)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = w
+
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(&stderr, log)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysqldump failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func (d mysqlDriver) Restore(ctx context.Context, db models.Database, r io.Reader) error {
+	cmd, cleanup, err := d.command(ctx, db, "mysql", db.Database)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdin = r
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysql restore failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+// Verify checks that the dump ends with the trailer mysqldump writes once
+// it has finished, so an interrupted dump is not mistaken for a valid one.
+func (mysqlDriver) Verify(r io.Reader) error {
+	tail := make([]byte, 0, 2*mysqlTrailerWindow)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if len(tail) > mysqlTrailerWindow {
+			tail = append(tail[:0], tail[len(tail)-mysqlTrailerWindow:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !bytes.Contains(tail, []byte(mysqlDumpTrailer)) {
+		return errors.New("mysqldump completion marker is missing, the dump is truncated")
+	}
+	return nil
+}
+
+func (d mysqlDriver) CreateSandbox(server models.Database, name string) (models.Database, error) {
+	if _, err := d.query(server, fmt.Sprintf("CREATE DATABASE `%s`", name)); err != nil {
+		return models.Database{}, err
+	}
+
+	sandbox := server
+	sandbox.Database = name
+	return sandbox, nil
+}
+
+func (d mysqlDriver) DropSandbox(sandbox models.Database) error {
+	_, err := d.query(sandbox, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", sandbox.Database))
+	return err
+}
+
+func (d mysqlDriver) QueryValue(ctx context.Context, sandbox models.Database, query string) (string, bool, error) {
+	cmd, cleanup, err := d.command(ctx, sandbox, "mysql", "-N", "-B", "-D", sandbox.Database, "-e", query)
+	if err != nil {
+		return "", false, err
+	}
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", false, fmt.Errorf("mysql query failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	out := strings.TrimSpace(stdout.String())
+	if out == "" {
+		return "", false, nil
+	}
+	row := strings.SplitN(out, "\n", 2)[0]
+	return strings.SplitN(row, "\t", 2)[0], true, nil
+}
+
+func (d mysqlDriver) TestConnection(db models.Database) error {
+	_, err := d.query(db, "SELECT 1")
+	return err
+}
+
+func (d mysqlDriver) ListDatabases(db models.Database) ([]string, error) {
+	out, err := d.query(db, "SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return []string{}, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func (d mysqlDriver) EstimateSize(db models.Database) (int64, error) {
+	out, err := d.query(db, fmt.Sprintf(
+		"SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables WHERE table_schema = '%s'",
+		strings.ReplaceAll(db.Database, "'", "''"),
+	))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(out, 10, 64)
+}
+
+func (d mysqlDriver) ServerVersion(db models.Database) (string, error) {
+	return d.query(db, "SELECT VERSION()")
+}
+
+// CheckPermissions runs the dump without its rows, which needs the same
+// privileges on tables, views and triggers as a full one (and PROCESS for
+// tablespaces since MySQL 8.0.21).
+func (d mysqlDriver) CheckPermissions(db models.Database) []PermissionCheck {
+	return []PermissionCheck{checkOf("mysqldump --no-data", d.dumpSchema(db))}
+}
+
+func (d mysqlDriver) dumpSchema(db models.Database) error {
+	cmd, cleanup, err := d.command(context.Background(), db, "mysqldump",
+		"--no-data",
+		"--single-transaction",
+		"--lock-tables=false",
+		db.Database,
+	)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = io.Discard
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysqldump failed: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"fmt"
+	"net/url"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+// Options carries the per-run settings of a backup, resolved from the
+// database defaults and, for scheduled runs, the schedule overrides.
+type Options struct {
+	ScheduleID      string
+	Compression     Compression
+	StorageTargetID string
+	// ReplicaTargetIDs are the extra destinations of a scheduled backup
+	ReplicaTargetIDs []string
+	RequiredCopies   int
+	// Timeout bounds the whole run, upload included; zero means none
+	Timeout time.Duration
+	// Progress receives phase changes, byte counts and tool output; optional
+	Progress Reporter
+	// Wait is how long the run was queued before it started
+	Wait time.Duration
+	// RetryOf is the first backup of a scheduled run being retried, and
+	// Retry the number of this retry
+	RetryOf string
+	Retry   int
+}
+
+func OptionsFor(db models.Database, schedule *models.BackupSchedule) Options {
+	opts := Options{
+		Compression:     Compression{Algorithm: db.Compression, Level: db.CompressionLevel},
+		StorageTargetID: db.StorageTargetID,
+		Timeout:         time.Duration(db.TimeoutMinutes) * time.Minute,
+	}
+
+	if schedule != nil {
+		opts.ScheduleID = schedule.ID
+		if schedule.Compression != "" {
+			opts.Compression = Compression{Algorithm: schedule.Compression, Level: schedule.CompressionLevel}
+		}
+		if schedule.StorageTargetID != "" {
+			opts.StorageTargetID = schedule.StorageTargetID
+		}
+		if schedule.TimeoutMinutes > 0 {
+			opts.Timeout = time.Duration(schedule.TimeoutMinutes) * time.Minute
+		}
+		opts.ReplicaTargetIDs = schedule.ReplicaTargetIDs
+		opts.RequiredCopies = schedule.MinCopies
+	}
+
+	if opts.RequiredCopies <= 0 {
+		opts.RequiredCopies = 1 + len(opts.ReplicaTargetIDs)
+	}
+
+	if opts.Compression.Algorithm == "" {
+		opts.Compression.Algorithm = CompressionNone
+	}
+
+	return opts
+}
+
+// HostKey identifies the server holding db, to limit the dumps run against
+// it at once: host:port, the hosts of a MongoDB connection URI, or the file
+// of a SQLite database.
+func HostKey(db models.Database) string {
+	if db.ConnectionURI != "" {
+		if u, err := url.Parse(db.ConnectionURI); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	if db.Type == "sqlite" || db.Port == 0 {
+		return db.Host
+	}
+	return fmt.Sprintf("%s:%d", db.Host, db.Port)
+}
+
+// StorageKey identifies the storage target a backup is written to, to limit
+// the uploads to it at once.
+func StorageKey(targetID string) string {
+	if targetID == "" {
+		return "local"
+	}
+	return targetID
+}
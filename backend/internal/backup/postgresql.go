@@ -0,0 +1,282 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	postgresContainer = "safebase-postgres"
+	postgresDumpMagic = "PGDMP"
+)
+
+type postgresDriver struct{}
+
+func init() {
+	RegisterDriver("postgresql", postgresDriver{})
+}
+
+func (postgresDriver) Extension() string {
+	return ".dump"
+}
+
+// command builds a psql/pg_dump/pg_restore invocation on the host. The
+// password is written to a private .pgpass file named by PGPASSFILE; the
+// returned cleanup func removes that file.
+func (postgresDriver) command(ctx context.Context, db models.Database, name string, args ...string) (*exec.Cmd, func(), error) {
+	connArgs := []string{
+		"-h", connectHost(db),
+		"-p", fmt.Sprintf("%d", db.Port),
+		"-U", db.Username,
+	}
+	cmd := commandContext(ctx, findCommand(name), append(connArgs, args...)...)
+	if db.Password == "" {
+		return cmd, func() {}, nil
+	}
+
+	passFile, cleanup, err := credentialFile("pgpass", pgpassEntry(db.Password))
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.Env = append(os.Environ(), "PGPASSFILE="+passFile)
+	return cmd, cleanup, nil
+}
+
+// useContainer reports whether a local database should be reached through
+// the safebase-postgres container (local development outside Docker) rather
+// than with the pg_dump/pg_restore found on the host. The local end of an
+// SSH tunnel is only reachable from the host.
+func (postgresDriver) useContainer(db models.Database) bool {
+	if db.SSHHost != "" || (db.Host != "localhost" && db.Host != "127.0.0.1") {
+		return false
+	}
+	out, err := exec.Command("docker", "inspect", "-f", "{{.State.Running}}", postgresContainer).Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// containerCommand builds a docker exec invocation of a tool inside the
+// container. The .pgpass file is copied into the container rather than the
+// password passed on the docker command line; the returned cleanup func
+// removes it from there.
+func (postgresDriver) containerCommand(ctx context.Context, db models.Database, name string, args ...string) (*exec.Cmd, func(), error) {
+	dockerArgs := []string{"exec", "-i"}
+	cleanup := func() {}
+	if db.Password != "" {
+		passFile, removeLocal, err := credentialFile("pgpass", pgpassEntry(db.Password))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer removeLocal()
+
+		containerPath := "/tmp/" + filepath.Base(filepath.Dir(passFile)) + ".pgpass"
+		if err := copyToContainer(passFile, postgresContainer, containerPath); err != nil {
+			return nil, nil, err
+		}
+		cleanup = func() {
+			exec.Command("docker", "exec", postgresContainer, "rm", "-f", containerPath).Run()
+		}
+		dockerArgs = append(dockerArgs, "-e", "PGPASSFILE="+containerPath)
+	}
+
+	dockerArgs = append(dockerArgs, postgresContainer, name, "-U", db.Username)
+	return commandContext(ctx, "docker", append(dockerArgs, args...)...), cleanup, nil
+}
+
+// toolCommand runs pg_dump or pg_restore inside the container for local
+// development, and directly otherwise (which works in Docker with service
+// names like "postgresql" or external hosts).
+func (d postgresDriver) toolCommand(ctx context.Context, db models.Database, name string, args ...string) (*exec.Cmd, func(), error) {
+	if d.useContainer(db) {
+		return d.containerCommand(ctx, db, name, args...)
+	}
+	return d.command(ctx, db, name, args...)
+}
+
+func (d postgresDriver) query(db models.Database, sql string) (string, error) {
+	return d.queryContext(context.Background(), db, sql)
+}
+
+func (d postgresDriver) queryContext(ctx context.Context, db models.Database, sql string) (string, error) {
+	cmd, cleanup, err := d.command(ctx, db, "psql", "-d", db.Database, "-t", "-A", "-c", sql)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("psql query failed: %v, stderr: %s", err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (d postgresDriver) Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error {
+	cmd, cleanup, err := d.toolCommand(ctx, db, "pg_dump", "-d", db.Database, "-F", "c")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = w
+
+	var stderr bytes.Buffer
+	cmd.Stderr = io.MultiWriter(&stderr, log)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func (d postgresDriver) Restore(ctx context.Context, db models.Database, r io.Reader) error {
+	cmd, cleanup, err := d.toolCommand(ctx, db, "pg_restore", "-d", db.Database, "--clean", "--if-exists", "--no-owner")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdin = r
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_restore failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return nil
+}
+
+// Verify checks the custom-format header and, when pg_restore is installed,
+// that the archive table of contents can be listed.
+func (postgresDriver) Verify(r io.Reader) error {
+	header := make([]byte, len(postgresDumpMagic))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("dump is too short: %v", err)
+	}
+	if string(header) != postgresDumpMagic {
+		return errors.New("not a pg_dump custom-format archive")
+	}
+
+	pgRestore, err := exec.LookPath(findCommand("pg_restore"))
+	if err != nil {
+		return nil
+	}
+	cmd := exec.Command(pgRestore, "--list")
+	cmd.Stdin = io.MultiReader(bytes.NewReader(header), r)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_restore --list failed: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
+
+// CreateSandbox creates a scratch database through the server's "postgres"
+// maintenance database.
+func (d postgresDriver) CreateSandbox(server models.Database, name string) (models.Database, error) {
+	admin := server
+	admin.Database = "postgres"
+	if _, err := d.query(admin, fmt.Sprintf(`CREATE DATABASE "%s"`, name)); err != nil {
+		return models.Database{}, err
+	}
+
+	sandbox := server
+	sandbox.Database = name
+	return sandbox, nil
+}
+
+func (d postgresDriver) DropSandbox(sandbox models.Database) error {
+	admin := sandbox
+	admin.Database = "postgres"
+	_, err := d.query(admin, fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, sandbox.Database))
+	return err
+}
+
+func (d postgresDriver) QueryValue(ctx context.Context, sandbox models.Database, query string) (string, bool, error) {
+	out, err := d.queryContext(ctx, sandbox, query)
+	if err != nil || out == "" {
+		return "", false, err
+	}
+	row := strings.SplitN(out, "\n", 2)[0]
+	return strings.SplitN(row, "|", 2)[0], true, nil
+}
+
+func (d postgresDriver) TestConnection(db models.Database) error {
+	_, err := d.query(db, "SELECT 1")
+	return err
+}
+
+func (d postgresDriver) ListDatabases(db models.Database) ([]string, error) {
+	out, err := d.query(db, "SELECT datname FROM pg_database WHERE NOT datistemplate ORDER BY datname")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return []string{}, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func (d postgresDriver) EstimateSize(db models.Database) (int64, error) {
+	out, err := d.query(db, "SELECT pg_database_size(current_database())")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(out, 10, 64)
+}
+
+func (d postgresDriver) ServerVersion(db models.Database) (string, error) {
+	return d.query(db, "SHOW server_version")
+}
+
+// unreadablePostgresTables counts the tables and sequences of the database
+// the user cannot SELECT from, each of which would make pg_dump fail.
+const unreadablePostgresTables = `SELECT count(*) FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'p', 'm', 'S')
+AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+AND NOT has_table_privilege(c.oid, 'SELECT')`
+
+// CheckPermissions runs a schema-only pg_dump, which takes the same locks as
+// a full dump and fails when pg_dump is older than the server, then checks
+// that every table can be read.
+func (d postgresDriver) CheckPermissions(db models.Database) []PermissionCheck {
+	checks := []PermissionCheck{checkOf("pg_dump --schema-only", d.dumpSchema(db))}
+
+	out, err := d.query(db, unreadablePostgresTables)
+	if err == nil && out != "0" {
+		err = fmt.Errorf("%s tables or sequences cannot be read", out)
+	}
+	return append(checks, checkOf("SELECT on every table", err))
+}
+
+func (d postgresDriver) dumpSchema(db models.Database) error {
+	cmd, cleanup, err := d.toolCommand(context.Background(), db, "pg_dump", "-d", db.Database, "--schema-only")
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Stdout = io.Discard
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pg_dump failed: %v, stderr: %s", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"context"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+// Inspector is implemented by drivers that can describe the server behind a
+// connection and check that its user holds what a backup needs.
+type Inspector interface {
+	ServerVersion(db models.Database) (string, error)
+	// CheckPermissions runs the checks a backup of db depends on, such as a
+	// schema-only dump, without writing anything.
+	CheckPermissions(db models.Database) []PermissionCheck
+}
+
+type PermissionCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+func checkOf(name string, err error) PermissionCheck {
+	check := PermissionCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// ConnectionReport is the outcome of actually connecting to a database.
+type ConnectionReport struct {
+	Connected bool `json:"connected"`
+	// CanBackup is set when every permission check passed
+	CanBackup   bool              `json:"canBackup"`
+	Version     string            `json:"version,omitempty"`
+	LatencyMs   int64             `json:"latencyMs"`
+	SizeBytes   int64             `json:"sizeBytes"`
+	Size        string            `json:"size,omitempty"`
+	Permissions []PermissionCheck `json:"permissions"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Status maps the report onto Database.Status: connected, error when the
+// server answers but a backup would fail, or disconnected.
+func (r ConnectionReport) Status() string {
+	switch {
+	case !r.Connected:
+		return "disconnected"
+	case !r.CanBackup:
+		return "error"
+	}
+	return "connected"
+}
+
+// Probe connects to db and reports its version, size and whether its user
+// can back it up. The latency is that of the connection test alone.
+func (be *BackupExecutor) Probe(db models.Database) ConnectionReport {
+	report := ConnectionReport{Permissions: []PermissionCheck{}}
+	driver, err := GetDriver(db.Type)
+	if err == nil {
+		var closeTunnel func()
+		db, closeTunnel, err = be.connect(context.Background(), db)
+		if err == nil {
+			defer closeTunnel()
+		}
+	}
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	start := time.Now()
+	err = driver.TestConnection(db)
+	report.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Connected = true
+
+	if size, err := driver.EstimateSize(db); err == nil {
+		report.SizeBytes = size
+		report.Size = formatSize(size)
+	}
+
+	report.CanBackup = true
+	inspector, ok := driver.(Inspector)
+	if !ok {
+		return report
+	}
+	if version, err := inspector.ServerVersion(db); err == nil {
+		report.Version = version
+	}
+	report.Permissions = inspector.CheckPermissions(db)
+	for _, check := range report.Permissions {
+		if !check.Passed {
+			report.CanBackup = false
+			if report.Error == "" {
+				report.Error = check.Name + ": " + check.Error
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/secrets"
+	"testing"
+	"time"
+)
+
+func TestProbeSQLite(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "app.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	be := NewBackupExecutor(t.TempDir())
+	be.SQLiteRoot = dir
+	report := be.Probe(models.Database{Type: "sqlite", Host: dbPath})
+	if report.Status() != "connected" || report.Version == "" || report.SizeBytes == 0 {
+		t.Fatalf("Expected a connected database with a version and a size, got %+v", report)
+	}
+
+	// A file that is not a database opens, but cannot be backed up
+	notDB := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notDB, []byte("not a database, just some text long enough to be read as a header"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if report := be.Probe(models.Database{Type: "sqlite", Host: notDB}); report.CanBackup || report.Error == "" {
+		t.Errorf("Expected the permission check to fail, got %+v", report)
+	}
+
+	if report := be.Probe(models.Database{Type: "sqlite", Host: filepath.Join(dir, "missing.db")}); report.Status() != "disconnected" {
+		t.Errorf("Expected a missing file to be disconnected, got %+v", report)
+	}
+}
+
+func TestCredentialsResolveSecret(t *testing.T) {
+	t.Setenv("SAFEBASE_SECRET_PROD_PASSWORD", "s3cret")
+	be := NewBackupExecutor(t.TempDir())
+	db := models.Database{Type: "postgresql", PasswordRef: "env:SAFEBASE_SECRET_PROD_PASSWORD"}
+
+	if _, err := be.Credentials(context.Background(), db); err == nil {
+		t.Fatal("Expected a reference to fail without secret providers")
+	}
+
+	be.Secrets = secrets.NewResolver(time.Minute)
+	resolved, err := be.Credentials(context.Background(), db)
+	if err != nil || resolved.Password != "s3cret" {
+		t.Fatalf("Expected the password from the environment, got %q, %v", resolved.Password, err)
+	}
+
+	db.PasswordRef = "env:SAFEBASE_SECRET_UNSET_PASSWORD"
+	if _, err := be.Credentials(context.Background(), db); err == nil || IsTransient(err) {
+		t.Errorf("Expected an unset variable to fail permanently, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// Phases reported while a backup runs. Dumping, compressing and uploading
+// overlap since the artifact is streamed; a phase starts when the previous
+// stage has finished feeding it.
+const (
+	PhaseConnecting  = "connecting"
+	PhaseDumping     = "dumping"
+	PhaseCompressing = "compressing"
+	PhaseUploading   = "uploading"
+	PhaseVerifying   = "verifying"
+	PhaseReplicating = "replicating"
+)
+
+const (
+	EventPhase = "phase"
+	EventBytes = "bytes"
+	EventLog   = "log"
+
+	bytesReportInterval = 500 * time.Millisecond
+	maxLogLineLength    = 4096
+)
+
+type ProgressEvent struct {
+	Type     string    `json:"type"`
+	Phase    string    `json:"phase,omitempty"`
+	RawBytes int64     `json:"rawBytes,omitempty"`
+	Bytes    int64     `json:"bytes,omitempty"`
+	Line     string    `json:"line,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Reporter receives progress events of a backup. Report may be called from
+// several goroutines and must not block for long.
+type Reporter interface {
+	Report(event ProgressEvent)
+}
+
+type ReporterFunc func(event ProgressEvent)
+
+func (f ReporterFunc) Report(event ProgressEvent) {
+	f(event)
+}
+
+type nopReporter struct{}
+
+func (nopReporter) Report(ProgressEvent) {}
+
+func reporterOf(opts Options) Reporter {
+	if opts.Progress == nil {
+		return nopReporter{}
+	}
+	return opts.Progress
+}
+
+func reportPhase(r Reporter, phase string) {
+	r.Report(ProgressEvent{Type: EventPhase, Phase: phase, Time: time.Now()})
+}
+
+// byteReporter reports the dumping phase once the first bytes arrive, then
+// the dump and stored byte counts at most every bytesReportInterval.
+type byteReporter struct {
+	r            Reporter
+	raw, written *countingWriter
+	last         time.Time
+	dumping      bool
+}
+
+func (br *byteReporter) tick() {
+	if !br.dumping {
+		br.dumping = true
+		reportPhase(br.r, PhaseDumping)
+	}
+	if time.Since(br.last) < bytesReportInterval {
+		return
+	}
+	br.flush()
+}
+
+func (br *byteReporter) flush() {
+	br.last = time.Now()
+	br.r.Report(ProgressEvent{Type: EventBytes, RawBytes: br.raw.n, Bytes: br.written.n, Time: br.last})
+}
+
+type tickWriter struct {
+	w    *countingWriter
+	tick func()
+}
+
+func (tw tickWriter) Write(p []byte) (int, error) {
+	n, err := tw.w.Write(p)
+	tw.tick()
+	return n, err
+}
+
+// lineWriter forwards each complete line written to it as a log event. It
+// is handed to drivers as the destination of their tools' stderr.
+type lineWriter struct {
+	r   Reporter
+	mu  sync.Mutex
+	buf []byte
+}
+
+func newLineWriter(r Reporter) *lineWriter {
+	return &lineWriter{r: r}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.emit(lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+	if len(lw.buf) > maxLogLineLength {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush reports a trailing line without newline.
+func (lw *lineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.buf) > 0 {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+}
+
+func (lw *lineWriter) emit(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > maxLogLineLength {
+		line = line[:maxLogLineLength]
+	}
+	if len(line) == 0 {
+		return
+	}
+	lw.r.Report(ProgressEvent{Type: EventLog, Line: string(line), Time: time.Now()})
+}
@@ -0,0 +1,72 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"sync"
+	"testing"
+)
+
+func TestExecuteBackupReportsProgress(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "source.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var phases []string
+	var bytes ProgressEvent
+	opts := Options{Compression: Compression{Algorithm: CompressionZstd}}
+	opts.Progress = ReporterFunc(func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Type {
+		case EventPhase:
+			phases = append(phases, event.Phase)
+		case EventBytes:
+			bytes = event
+		}
+	})
+
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = dir
+	db := models.Database{ID: "db1", Name: "items", Type: "sqlite", Host: dbPath}
+	b, err := be.ExecuteBackup(context.Background(), db, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+
+	want := []string{PhaseConnecting, PhaseDumping, PhaseCompressing, PhaseUploading, PhaseVerifying}
+	if fmt.Sprint(phases) != fmt.Sprint(want) {
+		t.Errorf("Expected phases %v, got %v", want, phases)
+	}
+	if bytes.Bytes != b.SizeBytes || bytes.RawBytes == 0 {
+		t.Errorf("Expected final byte counts to match the backup, got %+v for %d bytes", bytes, b.SizeBytes)
+	}
+}
+
+func TestLineWriterSplitsLines(t *testing.T) {
+	var lines []string
+	lw := newLineWriter(ReporterFunc(func(event ProgressEvent) {
+		lines = append(lines, event.Line)
+	}))
+
+	fmt.Fprint(lw, "pg_dump: reading schemas\r\npg_dump: reading ")
+	fmt.Fprint(lw, "tables\n\npg_dump: done")
+	lw.Flush()
+
+	want := []string{"pg_dump: reading schemas", "pg_dump: reading tables", "pg_dump: done"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
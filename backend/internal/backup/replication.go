@@ -0,0 +1,87 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"safebase-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// replicate copies a freshly written backup to each replica target and
+// records the outcome per destination on backup.Replicas.
+func (be *BackupExecutor) replicate(ctx context.Context, backup *models.Backup, targetIDs []string) {
+	seen := map[string]bool{backup.StorageTargetID: true}
+	for _, targetID := range targetIDs {
+		if seen[targetID] {
+			continue
+		}
+		seen[targetID] = true
+
+		replica := models.BackupReplica{
+			ID:              uuid.New().String(),
+			BackupID:        backup.ID,
+			StorageTargetID: targetID,
+			CreatedAt:       time.Now(),
+		}
+		be.CopyReplica(ctx, *backup, &replica)
+		backup.Replicas = append(backup.Replicas, replica)
+	}
+}
+
+// CopyReplica uploads the primary artifact of backup to the replica target,
+// updating the replica status. It is also used to retry failed replicas.
+func (be *BackupExecutor) CopyReplica(ctx context.Context, backup models.Backup, replica *models.BackupReplica) error {
+	replica.Attempts++
+	replica.UpdatedAt = time.Now()
+
+	uri, err := be.copyArtifact(ctx, backup, replica.StorageTargetID)
+	if err != nil {
+		replica.Status = "failed"
+		replica.Error = err.Error()
+		return err
+	}
+
+	replica.Status = "success"
+	replica.Error = ""
+	replica.FilePath = uri
+	return nil
+}
+
+func (be *BackupExecutor) copyArtifact(ctx context.Context, backup models.Backup, targetID string) (string, error) {
+	src, key, err := be.locate(backup)
+	if err != nil {
+		return "", err
+	}
+	dst, err := be.storage(targetID)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := src.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read primary copy: %v", err)
+	}
+	defer r.Close()
+
+	if err := dst.Put(ctx, key, r); err != nil {
+		return "", fmt.Errorf("failed to upload replica: %v", err)
+	}
+	return dst.URI(key), nil
+}
+
+// CopyCount returns how many copies of backup currently exist: the primary
+// plus every successful replica.
+func CopyCount(backup models.Backup) int {
+	if backup.Status != "success" {
+		return 0
+	}
+	count := 1
+	for _, replica := range backup.Replicas {
+		if replica.Status == "success" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
+	"testing"
+)
+
+func TestExecuteBackupReplicates(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "source.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := map[string]storage.Storage{
+		"offsite": storage.NewLocal(filepath.Join(dir, "offsite")),
+	}
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = dir
+	be.OpenStorage = func(id string) (storage.Storage, error) {
+		if store, ok := targets[id]; ok {
+			return store, nil
+		}
+		return nil, fmt.Errorf("storage target %s not found", id)
+	}
+
+	db := models.Database{ID: "db1", Name: "items", Type: "sqlite", Host: dbPath}
+	opts := OptionsFor(db, &models.BackupSchedule{ReplicaTargetIDs: []string{"offsite", "missing"}})
+
+	b, err := be.ExecuteBackup(context.Background(), db, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+
+	if len(b.Replicas) != 2 {
+		t.Fatalf("Expected 2 replicas, got %d", len(b.Replicas))
+	}
+	if b.Replicas[0].Status != "success" || b.Replicas[1].Status != "failed" {
+		t.Errorf("Unexpected replica statuses: %s, %s", b.Replicas[0].Status, b.Replicas[1].Status)
+	}
+	if b.RequiredCopies != 3 || CopyCount(b) != 2 {
+		t.Errorf("Expected 2 of 3 copies, got %d of %d", CopyCount(b), b.RequiredCopies)
+	}
+
+	// The primary copy is gone: restore must fall back to the replica
+	store, key, err := be.locate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(nil, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := be.ExecuteRestore(context.Background(), b, db); err != nil {
+		t.Errorf("Expected restore from replica, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package backup
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExecuteRestore replays backup into target until done or ctx is cancelled.
+func (be *BackupExecutor) ExecuteRestore(ctx context.Context, backup models.Backup, target models.Database) (models.Restore, error) {
+	startTime := time.Now()
+	restore := models.Restore{
+		ID:                 uuid.New().String(),
+		BackupID:           backup.ID,
+		SourceDatabaseID:   backup.DatabaseID,
+		TargetDatabaseID:   target.ID,
+		TargetDatabaseName: target.Name,
+		Status:             "in_progress",
+		CreatedAt:          time.Now(),
+	}
+
+	err := be.connectAndRestore(ctx, backup, target)
+	restore.Duration = int(time.Since(startTime).Seconds())
+
+	if err != nil {
+		restore.Status = "failed"
+		restore.Error = err.Error()
+		return restore, err
+	}
+
+	restore.Status = "success"
+	return restore, nil
+}
+
+func (be *BackupExecutor) connectAndRestore(ctx context.Context, backup models.Backup, target models.Database) error {
+	if be.isSelf(target) {
+		return errors.New("SafeBase's own database cannot be restored into while it runs")
+	}
+	target, closeTunnel, err := be.connect(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer closeTunnel()
+	return be.restore(ctx, backup, target)
+}
+
+// restore replays backup into target, which must already be connected: its
+// password resolved and its tunnel, if any, open.
+func (be *BackupExecutor) restore(ctx context.Context, backup models.Backup, target models.Database) error {
+	if backup.Status != "success" || backup.FilePath == "" {
+		return fmt.Errorf("backup %s is not restorable (status: %s)", backup.ID, backup.Status)
+	}
+
+	driver, err := GetDriver(target.Type)
+	if err != nil {
+		return err
+	}
+
+	dumpPath := strings.TrimSuffix(backup.FilePath, encryptedExtension)
+	dumpPath = strings.TrimSuffix(dumpPath, compressionExtension(backup.Compression))
+	if !strings.HasSuffix(dumpPath, driver.Extension()) {
+		return fmt.Errorf("cannot restore %s into a %s database", filepath.Base(backup.FilePath), target.Type)
+	}
+
+	artifact, err := be.openArtifact(ctx, backup)
+	if err != nil {
+		return fmt.Errorf("backup file not found: %v", err)
+	}
+	defer artifact.Close()
+
+	var input io.Reader = artifact
+	if backup.EncryptionKeyID != "" {
+		input, err = be.decrypt(backup, artifact)
+		if err != nil {
+			return err
+		}
+	}
+
+	reader, err := newDecompressReader(input, backup.Compression)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// As for Backup, the driver learns from the compression of the artifact
+	// whether its tools compressed the dump themselves
+	target.Compression = CompressionNone
+	if backup.RawDump {
+		target.Compression = backup.Compression
+	}
+
+	return driver.Restore(ctx, target, reader)
+}
+
+// locate returns the storage holding a backup artifact and its key there.
+// Backups taken before storage targets existed have a plain local path.
+func (be *BackupExecutor) locate(backup models.Backup) (storage.Storage, string, error) {
+	if !strings.Contains(backup.FilePath, "://") {
+		return storage.NewLocal(filepath.Dir(backup.FilePath)), filepath.Base(backup.FilePath), nil
+	}
+
+	store, err := be.storage(backup.StorageTargetID)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := storage.KeyFromURI(store, backup.FilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, key, nil
+}
+
+// openArtifact reads the primary copy of a backup, falling back to its
+// successful replicas when the primary is unavailable.
+func (be *BackupExecutor) openArtifact(ctx context.Context, backup models.Backup) (io.ReadCloser, error) {
+	store, key, err := be.locate(backup)
+	if err == nil {
+		var r io.ReadCloser
+		if r, err = store.Get(ctx, key); err == nil {
+			return r, nil
+		}
+	}
+
+	for _, replica := range backup.Replicas {
+		if replica.Status != "success" {
+			continue
+		}
+		replicaStore, replicaErr := be.storage(replica.StorageTargetID)
+		if replicaErr != nil {
+			continue
+		}
+		replicaKey, replicaErr := storage.KeyFromURI(replicaStore, replica.FilePath)
+		if replicaErr != nil {
+			continue
+		}
+		if r, replicaErr := replicaStore.Get(ctx, replicaKey); replicaErr == nil {
+			return r, nil
+		}
+	}
+
+	return nil, err
+}
+
+func (be *BackupExecutor) decrypt(backup models.Backup, r io.Reader) (io.Reader, error) {
+	dataKey, err := be.dataKey(backup)
+	if err != nil {
+		return nil, err
+	}
+	return newDecryptReader(r, dataKey)
+}
+
+// dataKey unwraps the data key an encrypted backup was written with.
+func (be *BackupExecutor) dataKey(backup models.Backup) ([]byte, error) {
+	if be.Keyring == nil {
+		return nil, fmt.Errorf("backup is encrypted with key %s but no encryption keys are configured", backup.EncryptionKeyID)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(backup.EncryptedDataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %v", err)
+	}
+
+	return be.Keyring.Unwrap(backup.EncryptionKeyID, wrapped, []byte(backup.ID))
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
+	"strings"
+	"testing"
+)
+
+func countItems(t *testing.T, path string) int {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM items").Scan(&count); err != nil {
+		return -1
+	}
+	return count
+}
+
+func TestExecuteRestoreIntoAnotherTarget(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "sqlite")
+	if err := os.Mkdir(root, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	sourcePath := filepath.Join(root, "shop.db")
+	conn, err := sql.Open("sqlite3", sourcePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('a'), ('b')"); err != nil {
+		t.Fatal(err)
+	}
+
+	targets := map[string]storage.Storage{
+		"archive": storage.NewLocal(filepath.Join(dir, "archive")),
+		"offsite": storage.NewLocal(filepath.Join(dir, "offsite")),
+	}
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = root
+	be.OpenStorage = func(id string) (storage.Storage, error) {
+		if store, ok := targets[id]; ok {
+			return store, nil
+		}
+		return nil, fmt.Errorf("storage target %s not found", id)
+	}
+
+	source := models.Database{ID: "shop", Name: "shop", Type: "sqlite", Host: sourcePath}
+	opts := OptionsFor(source, &models.BackupSchedule{StorageTargetID: "archive", ReplicaTargetIDs: []string{"offsite"}})
+	b, err := be.ExecuteBackup(context.Background(), source, opts)
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+
+	// The backup lands in another database than the one it was taken from
+	copyPath := filepath.Join(root, "shop_copy.db")
+	if err := os.WriteFile(copyPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := models.Database{ID: "shop-copy", Name: "shop copy", Type: "sqlite", Host: copyPath}
+	restore, err := be.ExecuteRestore(context.Background(), b, target)
+	if err != nil {
+		t.Fatalf("ExecuteRestore failed: %v", err)
+	}
+	if restore.Status != "success" || restore.SourceDatabaseID != "shop" || restore.TargetDatabaseID != "shop-copy" || restore.TargetDatabaseName != "shop copy" {
+		t.Errorf("Unexpected restore record %+v", restore)
+	}
+	if count := countItems(t, copyPath); count != 2 {
+		t.Errorf("Expected 2 rows in the target, got %d", count)
+	}
+
+	// Without the primary copy, the replica is restored
+	store, key, err := be.locate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(copyPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := be.ExecuteRestore(context.Background(), b, target); err != nil {
+		t.Fatalf("Expected restore from the replica, got %v", err)
+	}
+	if count := countItems(t, copyPath); count != 2 {
+		t.Errorf("Expected 2 rows restored from the replica, got %d", count)
+	}
+
+	// A target of another type is refused before anything is read
+	restore, err = be.ExecuteRestore(context.Background(), b, models.Database{ID: "pg", Type: "postgresql", Host: "db.internal"})
+	if err == nil || !strings.Contains(err.Error(), "cannot restore") || restore.Status != "failed" {
+		t.Errorf("Expected a type mismatch to fail the restore, got %v, %s", err, restore.Status)
+	}
+
+	// SafeBase's own database is never a target
+	be.Self = models.Database{ID: "safebase-self", Type: "sqlite", Host: filepath.Join(dir, "safebase.db")}
+	if restore, err := be.ExecuteRestore(context.Background(), b, be.Self); err == nil || !strings.Contains(err.Error(), "own database") || restore.Status != "failed" {
+		t.Errorf("Expected a restore into SafeBase's own database to fail, got %v, %s", err, restore.Status)
+	}
+
+	// With no copy left, the restore fails
+	replicaStore, _ := be.OpenStorage("offsite")
+	replicaKey, err := storage.KeyFromURI(replicaStore, b.Replicas[0].FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replicaStore.Delete(context.Background(), replicaKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := be.ExecuteRestore(context.Background(), b, target); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a restore without any copy to fail, got %v", err)
+	}
+}
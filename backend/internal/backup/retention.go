@@ -0,0 +1,184 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which backups of a schedule are kept. A backup is
+// kept when any rule keeps it; MaxTotalSize then drops the oldest kept
+// backups until the rest fit. The most recent successful backup is always
+// kept.
+type RetentionPolicy struct {
+	KeepLast     int
+	KeepDaily    int
+	KeepWeekly   int
+	KeepMonthly  int
+	MaxTotalSize int64
+}
+
+func RetentionFor(schedule models.BackupSchedule) RetentionPolicy {
+	return RetentionPolicy{
+		KeepLast:     schedule.KeepLast,
+		KeepDaily:    schedule.KeepDaily,
+		KeepWeekly:   schedule.KeepWeekly,
+		KeepMonthly:  schedule.KeepMonthly,
+		MaxTotalSize: schedule.MaxTotalSizeBytes,
+	}
+}
+
+// Enabled reports whether the policy has any rule. Schedules without rules
+// keep every backup.
+func (p RetentionPolicy) Enabled() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0 || p.MaxTotalSize > 0
+}
+
+func (p RetentionPolicy) hasCountRules() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0
+}
+
+func ValidateRetention(p RetentionPolicy) error {
+	if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 || p.KeepMonthly < 0 || p.MaxTotalSize < 0 {
+		return errors.New("retention rules cannot be negative")
+	}
+	return nil
+}
+
+// SelectPrunable returns the backups the policy does not keep, oldest first.
+// Backups still in progress are never pruned; failed, cancelled, missed and
+// corrupted ones are pruned once they are older than every kept backup.
+func SelectPrunable(backups []models.Backup, p RetentionPolicy, now time.Time) []models.Backup {
+	if !p.Enabled() {
+		return nil
+	}
+
+	sorted := append([]models.Backup(nil), backups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	var successful []models.Backup
+	for _, b := range sorted {
+		if b.Status == "success" {
+			successful = append(successful, b)
+		}
+	}
+
+	keep := map[string]bool{}
+	if p.hasCountRules() {
+		for i, b := range successful {
+			if i < p.KeepLast {
+				keep[b.ID] = true
+			}
+		}
+		keepNewestPer(successful, keep, now.AddDate(0, 0, -p.KeepDaily), p.KeepDaily, func(t time.Time) string {
+			return t.Format("2006-01-02")
+		})
+		keepNewestPer(successful, keep, now.AddDate(0, 0, -7*p.KeepWeekly), p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		})
+		keepNewestPer(successful, keep, now.AddDate(0, -p.KeepMonthly, 0), p.KeepMonthly, func(t time.Time) string {
+			return t.Format("2006-01")
+		})
+	} else {
+		for _, b := range successful {
+			keep[b.ID] = true
+		}
+	}
+	if len(successful) > 0 {
+		keep[successful[0].ID] = true
+	}
+
+	if p.MaxTotalSize > 0 {
+		var total int64
+		for i, b := range successful {
+			if !keep[b.ID] {
+				continue
+			}
+			total += b.SizeBytes
+			if total > p.MaxTotalSize && i > 0 {
+				delete(keep, b.ID)
+			}
+		}
+	}
+
+	var oldestKept time.Time
+	for _, b := range successful {
+		if keep[b.ID] {
+			oldestKept = b.CreatedAt
+		}
+	}
+
+	prunable := []models.Backup{}
+	for i := len(sorted) - 1; i >= 0; i-- {
+		b := sorted[i]
+		switch {
+		case b.Status == "success" && !keep[b.ID]:
+			prunable = append(prunable, b)
+		case (b.Status == "failed" || b.Status == "cancelled" || b.Status == "missed" || b.Status == "corrupted") && b.CreatedAt.Before(oldestKept):
+			prunable = append(prunable, b)
+		}
+	}
+	return prunable
+}
+
+// keepNewestPer keeps the newest backup of each period, as named by bucket,
+// among the backups taken after since. backups must be newest first.
+func keepNewestPer(backups []models.Backup, keep map[string]bool, since time.Time, periods int, bucket func(time.Time) string) {
+	if periods <= 0 {
+		return
+	}
+	seen := map[string]bool{}
+	for _, b := range backups {
+		if !b.CreatedAt.After(since) {
+			break
+		}
+		period := bucket(b.CreatedAt)
+		if !seen[period] {
+			seen[period] = true
+			keep[b.ID] = true
+		}
+	}
+}
+
+// DeleteArtifacts removes every stored copy of backup. Copies that are
+// already gone are not an error.
+func (be *BackupExecutor) DeleteArtifacts(backup models.Backup) error {
+	if backup.FilePath == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	store, key, err := be.locate(backup)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %v", backup.FilePath, err)
+	}
+
+	for _, replica := range backup.Replicas {
+		if replica.FilePath == "" {
+			continue
+		}
+		replicaStore, err := be.storage(replica.StorageTargetID)
+		if err != nil {
+			return err
+		}
+		replicaKey, err := storage.KeyFromURI(replicaStore, replica.FilePath)
+		if err != nil {
+			return err
+		}
+		if err := replicaStore.Delete(ctx, replicaKey); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete %s: %v", replica.FilePath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"fmt"
+	"safebase-backend/internal/models"
+	"testing"
+	"time"
+)
+
+// dailyBackups returns one successful backup per day at noon, newest first,
+// with IDs "d0" (today) to "d<n-1>".
+func dailyBackups(now time.Time, n int) []models.Backup {
+	backups := make([]models.Backup, n)
+	for i := range backups {
+		backups[i] = models.Backup{
+			ID:        fmt.Sprintf("d%d", i),
+			Status:    "success",
+			SizeBytes: 100,
+			CreatedAt: now.AddDate(0, 0, -i),
+		}
+	}
+	return backups
+}
+
+func ids(backups []models.Backup) map[string]bool {
+	set := map[string]bool{}
+	for _, b := range backups {
+		set[b.ID] = true
+	}
+	return set
+}
+
+func TestSelectPrunableNoRules(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	if got := SelectPrunable(dailyBackups(now, 10), RetentionPolicy{}, now); len(got) != 0 {
+		t.Errorf("Expected nothing pruned without rules, got %d", len(got))
+	}
+}
+
+func TestSelectPrunableKeepLast(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	got := SelectPrunable(dailyBackups(now, 10), RetentionPolicy{KeepLast: 3}, now)
+
+	if len(got) != 7 {
+		t.Fatalf("Expected 7 pruned, got %d", len(got))
+	}
+	if got[0].ID != "d9" || got[6].ID != "d3" {
+		t.Errorf("Expected oldest first from d9 to d3, got %s to %s", got[0].ID, got[6].ID)
+	}
+}
+
+func TestSelectPrunableGFS(t *testing.T) {
+	// Saturday 15 March 2025, with 120 days of daily backups
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	backups := dailyBackups(now, 120)
+	// A second backup today only keeps the newest of the day
+	backups = append(backups, models.Backup{ID: "early", Status: "success", CreatedAt: now.Add(-2 * time.Hour)})
+
+	pruned := ids(SelectPrunable(backups, RetentionPolicy{KeepDaily: 7, KeepWeekly: 4, KeepMonthly: 3}, now))
+	kept := []string{}
+	for _, b := range backups {
+		if !pruned[b.ID] {
+			kept = append(kept, b.ID)
+		}
+	}
+
+	// 7 days, the Sundays closing the weeks back to 16 February (d6 already
+	// covers the week of 9 March), then the last day of February, January
+	// and December
+	want := []string{"d0", "d1", "d2", "d3", "d4", "d5", "d6", "d13", "d15", "d20", "d27", "d43", "d74"}
+	if fmt.Sprint(kept) != fmt.Sprint(want) {
+		t.Errorf("Expected kept %v, got %v", want, kept)
+	}
+}
+
+func TestSelectPrunableMaxTotalSize(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	pruned := ids(SelectPrunable(dailyBackups(now, 10), RetentionPolicy{MaxTotalSize: 350}, now))
+	if len(pruned) != 7 || pruned["d0"] || pruned["d2"] || !pruned["d3"] {
+		t.Errorf("Expected d3 to d9 pruned, got %v", pruned)
+	}
+
+	// The newest backup survives even when it alone exceeds the cap
+	pruned = ids(SelectPrunable(dailyBackups(now, 3), RetentionPolicy{MaxTotalSize: 50}, now))
+	if pruned["d0"] || len(pruned) != 2 {
+		t.Errorf("Expected only d1 and d2 pruned, got %v", pruned)
+	}
+}
+
+func TestSelectPrunableFailedAndInProgress(t *testing.T) {
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	backups := dailyBackups(now, 5)
+	backups = append(backups,
+		models.Backup{ID: "old-failed", Status: "failed", CreatedAt: now.AddDate(0, 0, -30)},
+		models.Backup{ID: "new-failed", Status: "failed", CreatedAt: now.Add(-time.Hour)},
+		models.Backup{ID: "running", Status: "in_progress", CreatedAt: now.AddDate(0, 0, -30)},
+	)
+
+	pruned := ids(SelectPrunable(backups, RetentionPolicy{KeepLast: 2}, now))
+	if !pruned["old-failed"] || pruned["new-failed"] || pruned["running"] {
+		t.Errorf("Unexpected pruning of failed or running backups: %v", pruned)
+	}
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"math"
+	"safebase-backend/internal/models"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryDelay   = time.Minute
+	defaultRetryBackoff = 2.0
+	// maxRetryDelay caps the backoff so a retry is never pushed past the
+	// next few scheduled runs
+	maxRetryDelay = 6 * time.Hour
+)
+
+// RetryPolicy decides when a failed scheduled backup is run again. Retry n
+// (from 1) waits InitialDelay * Backoff^(n-1), spread by up to Jitter
+// (a fraction of the delay) either way.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	Backoff      float64
+	Jitter       float64
+}
+
+func RetryFor(schedule models.BackupSchedule) RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts:  schedule.RetryMaxAttempts,
+		InitialDelay: time.Duration(schedule.RetryDelaySeconds) * time.Second,
+		Backoff:      schedule.RetryBackoff,
+		Jitter:       schedule.RetryJitter,
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = defaultRetryDelay
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaultRetryBackoff
+	}
+	return p
+}
+
+func ValidateRetry(p RetryPolicy) error {
+	if p.MaxAttempts < 0 || p.MaxAttempts > 10 {
+		return errors.New("retry attempts must be between 0 and 10")
+	}
+	if p.Backoff < 1 {
+		return errors.New("retry backoff factor must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("retry jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Delay returns how long to wait before retry n, or false when the policy
+// has no retry left. random, in [0, 1), places the delay within the jitter.
+func (p RetryPolicy) Delay(n int, random float64) (time.Duration, bool) {
+	if n < 1 || n > p.MaxAttempts {
+		return 0, false
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(p.Backoff, float64(n-1))
+	delay *= 1 + p.Jitter*(2*random-1)
+	if delay > float64(maxRetryDelay) {
+		delay = float64(maxRetryDelay)
+	}
+	return time.Duration(delay), true
+}
+
+// permanentErrors are fragments of tool and driver errors that running the
+// same backup again cannot fix.
+var permanentErrors = []string{
+	"password authentication failed",
+	"access denied",
+	"authentication failed",
+	"permission denied",
+	"unknown database",
+	"does not exist",
+	"unsupported database type",
+	"storage target",
+	"no encryption keys",
+	"no secret providers",
+	"is not set",
+}
+
+// IsTransient reports whether a backup error is worth retrying. Cancelled
+// runs and configuration errors, such as bad credentials or a missing
+// database, are permanent; anything else, a refused connection or a lock
+// timeout for instance, is assumed to be transient.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	if msg == "backup cancelled" {
+		return false
+	}
+	for _, fragment := range permanentErrors {
+		if strings.Contains(msg, fragment) {
+			return false
+		}
+	}
+	return true
+}
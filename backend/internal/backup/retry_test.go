@@ -0,0 +1,44 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryDelayBacksOff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialDelay: 30 * time.Second, Backoff: 2}
+
+	for n, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute} {
+		if got, ok := p.Delay(n, 0.5); !ok || got != want {
+			t.Errorf("Retry %d: expected %s, got %s (%v)", n, want, got, ok)
+		}
+	}
+	if _, ok := p.Delay(4, 0.5); ok {
+		t.Error("Expected no retry after MaxAttempts")
+	}
+
+	p.Jitter = 0.2
+	if low, _ := p.Delay(1, 0); low != 24*time.Second {
+		t.Errorf("Expected the jitter to shorten the delay to 24s, got %s", low)
+	}
+	if high, _ := p.Delay(1, 0.999999); high <= 35*time.Second || high > 36*time.Second {
+		t.Errorf("Expected the jitter to lengthen the delay to about 36s, got %s", high)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"mysqldump failed: exit status 2, stderr: Can't connect to MySQL server on 'db' (111 \"Connection refused\")": true,
+		"mysqldump failed: exit status 2, stderr: Lock wait timeout exceeded; try restarting transaction":             true,
+		"backup timed out after 30m0s": true,
+		"pg_dump failed: exit status 1, stderr: FATAL:  password authentication failed for user \"app\"": false,
+		"mysqldump failed: exit status 2, stderr: Access denied for user 'app'@'%'":                      false,
+		"pg_dump failed: exit status 1, stderr: FATAL:  database \"shop\" does not exist":                false,
+		"backup cancelled": false,
+	} {
+		if got := IsTransient(errors.New(msg)); got != want {
+			t.Errorf("IsTransient(%q) = %v, expected %v", msg, got, want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteRestoreBatch is the number of pages a restore copies between two
+// checks for cancellation.
+const sqliteRestoreBatch = 1024
+
+// sqliteDriver backs up SQLite files. The database "host" is the path of the
+// file on the SafeBase server; port and credentials are ignored.
+type sqliteDriver struct{}
+
+func init() {
+	RegisterDriver("sqlite", sqliteDriver{})
+}
+
+func (sqliteDriver) Extension() string {
+	return ".sqlite"
+}
+
+// isSelf reports whether db is SafeBase's own metadata database.
+func (be *BackupExecutor) isSelf(db models.Database) bool {
+	return be.Self.ID != "" && db.ID == be.Self.ID && db.Host == be.Self.Host
+}
+
+// ValidateSQLite checks that the file of a SQLite database, or the
+// directory of a drill sandbox server, is within SQLiteRoot.
+func (be *BackupExecutor) ValidateSQLite(db models.Database) error {
+	if db.Type != "sqlite" || be.isSelf(db) {
+		return nil
+	}
+	if be.SQLiteRoot == "" {
+		return errors.New("sqlite databases are disabled: no SQLite root directory is configured")
+	}
+	if !filepath.IsAbs(db.Host) {
+		return fmt.Errorf("sqlite path %s must be absolute", db.Host)
+	}
+	root, err := realPath(be.SQLiteRoot)
+	if err != nil {
+		return err
+	}
+	path, err := realPath(db.Host)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return fmt.Errorf("sqlite path %s is outside the SQLite root directory %s", db.Host, be.SQLiteRoot)
+	}
+	return nil
+}
+
+// realPath resolves the symlinks of path, or of its longest existing parent
+// when it does not exist yet, such as a sandbox directory.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return "", err
+		}
+		missing = append([]string{filepath.Base(path)}, missing...)
+		path = parent
+	}
+}
+
+func (sqliteDriver) open(path, mode string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("sqlite database not found: %v", err)
+	}
+	dsn := (&url.URL{Scheme: "file", Opaque: path, RawQuery: "mode=" + mode + "&_busy_timeout=5000"}).String()
+	return sql.Open("sqlite3", dsn)
+}
+
+// Backup takes a transactionally consistent snapshot with VACUUM INTO, which
+// is safe while other connections keep writing to the live file.
+func (d sqliteDriver) Backup(ctx context.Context, db models.Database, w io.Writer, log io.Writer) error {
+	conn, err := d.open(db.Host, "ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tempDir, err := os.MkdirTemp("", "safebase-sqlite-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	snapshotPath := filepath.Join(tempDir, "snapshot.db")
+	if _, err := conn.ExecContext(ctx, "VACUUM INTO ?", snapshotPath); err != nil {
+		return fmt.Errorf("sqlite snapshot failed: %v", err)
+	}
+
+	snapshot, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	_, err = io.Copy(w, snapshot)
+	return err
+}
+
+// spool writes a snapshot read from r to a private temporary file; cleanup
+// removes it.
+func (sqliteDriver) spool(r io.Reader) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "safebase-sqlite-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	snapshotPath := filepath.Join(tempDir, "snapshot.db")
+	snapshot, err := os.Create(snapshotPath)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	_, err = io.Copy(snapshot, r)
+	if closeErr := snapshot.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return snapshotPath, cleanup, nil
+}
+
+// Restore copies the snapshot over the live database through the online
+// backup API, so open connections see the restored pages instead of a file
+// swapped out from under them. The pages are copied in batches so that a
+// cancelled ctx stops the copy between two of them.
+func (d sqliteDriver) Restore(ctx context.Context, db models.Database, r io.Reader) error {
+	snapshotPath, cleanup, err := d.spool(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	src, err := d.open(snapshotPath, "ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := d.open(db.Host, "rw")
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dstRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			dstSQLite, ok := dstRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected sqlite connection type %T", dstRaw)
+			}
+			srcSQLite, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected sqlite connection type %T", srcRaw)
+			}
+
+			backup, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("sqlite restore failed: %v", err)
+			}
+			for done := false; !done; {
+				if err := ctx.Err(); err != nil {
+					backup.Finish()
+					return err
+				}
+				if done, err = backup.Step(sqliteRestoreBatch); err != nil {
+					backup.Finish()
+					return fmt.Errorf("sqlite restore failed: %v", err)
+				}
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// Verify runs SQLite's quick_check on a copy of the snapshot.
+func (d sqliteDriver) Verify(r io.Reader) error {
+	snapshotPath, cleanup, err := d.spool(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	conn, err := d.open(snapshotPath, "ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return fmt.Errorf("sqlite integrity check failed: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("sqlite integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// CreateSandbox creates an empty database file in the server "host", which
+// for SQLite sandboxes is a directory.
+func (sqliteDriver) CreateSandbox(server models.Database, name string) (models.Database, error) {
+	if err := os.MkdirAll(server.Host, 0700); err != nil {
+		return models.Database{}, err
+	}
+	path := filepath.Join(server.Host, name+".sqlite")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		return models.Database{}, err
+	}
+
+	sandbox := server
+	sandbox.Host = path
+	sandbox.Database = name
+	return sandbox, nil
+}
+
+func (sqliteDriver) DropSandbox(sandbox models.Database) error {
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Remove(sandbox.Host + suffix)
+	}
+	return os.Remove(sandbox.Host)
+}
+
+func (d sqliteDriver) QueryValue(ctx context.Context, sandbox models.Database, query string) (string, bool, error) {
+	conn, err := d.open(sandbox.Host, "ro")
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", false, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return "", false, err
+	}
+
+	switch v := values[0].(type) {
+	case nil:
+		return "", true, nil
+	case []byte:
+		return string(v), true, nil
+	default:
+		return fmt.Sprint(v), true, nil
+	}
+}
+
+func (d sqliteDriver) TestConnection(db models.Database) error {
+	conn, err := d.open(db.Host, "ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var ok int
+	return conn.QueryRow("SELECT 1").Scan(&ok)
+}
+
+func (d sqliteDriver) ListDatabases(db models.Database) ([]string, error) {
+	conn, err := d.open(db.Host, "ro")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query("PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (d sqliteDriver) EstimateSize(db models.Database) (int64, error) {
+	conn, err := d.open(db.Host, "ro")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var pageCount, pageSize int64
+	if err := conn.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return 0, err
+	}
+	if err := conn.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return 0, err
+	}
+	return pageCount * pageSize, nil
+}
+
+func (d sqliteDriver) ServerVersion(db models.Database) (string, error) {
+	version, _, _ := sqlite3.Version()
+	return version, nil
+}
+
+// CheckPermissions reads the schema, which unlike the connection test fails
+// on a file that is not a SQLite database.
+func (d sqliteDriver) CheckPermissions(db models.Database) []PermissionCheck {
+	conn, err := d.open(db.Host, "ro")
+	if err == nil {
+		defer conn.Close()
+		var tables int
+		err = conn.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&tables)
+	}
+	return []PermissionCheck{checkOf("read schema", err)}
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"testing"
+)
+
+func TestSQLiteBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "source.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('a'), ('b')"); err != nil {
+		t.Fatal(err)
+	}
+
+	driver := sqliteDriver{}
+	db := models.Database{Type: "sqlite", Host: dbPath}
+	var snapshot bytes.Buffer
+
+	if err := driver.Backup(context.Background(), db, &snapshot, io.Discard); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	if _, err := conn.Exec("DELETE FROM items"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cancelled restore leaves the database as it was
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := driver.Restore(cancelled, db, bytes.NewReader(snapshot.Bytes())); err == nil {
+		t.Error("Expected a cancelled restore to fail")
+	}
+	var remaining int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM items").Scan(&remaining); err != nil || remaining != 0 {
+		t.Errorf("Expected the cancelled restore to copy nothing, got %d rows, %v", remaining, err)
+	}
+
+	if err := driver.Restore(context.Background(), db, &snapshot); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM items").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 rows after restore, got %d", count)
+	}
+}
+
+func TestSQLitePathsStayWithinRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "sqlite")
+	if err := os.Mkdir(root, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	selfPath := filepath.Join(dir, "safebase.db")
+	if err := os.WriteFile(selfPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(selfPath, filepath.Join(root, "link.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = root
+	be.Self = models.Database{ID: "safebase-self", Type: "sqlite", Host: selfPath}
+
+	for _, host := range []string{filepath.Join(root, "shop.db"), filepath.Join(root, "drills")} {
+		if err := be.ValidateSQLite(models.Database{Type: "sqlite", Host: host}); err != nil {
+			t.Errorf("Expected %s to be allowed, got %v", host, err)
+		}
+	}
+	for _, host := range []string{selfPath, filepath.Join(root, "..", "safebase.db"), filepath.Join(root, "link.db"), "/etc/passwd", "shop.db", root} {
+		if err := be.ValidateSQLite(models.Database{ID: "db1", Type: "sqlite", Host: host}); err == nil {
+			t.Errorf("Expected %s to be rejected", host)
+		}
+	}
+
+	// SafeBase's own database may be backed up, but never restored into
+	if err := be.ValidateSQLite(be.Self); err != nil {
+		t.Errorf("Expected the self backup to be allowed, got %v", err)
+	}
+	if err := be.connectAndRestore(context.Background(), models.Backup{Status: "success", FilePath: "x.sqlite"}, be.Self); err == nil {
+		t.Error("Expected a restore into SafeBase's own database to be rejected")
+	}
+}
@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"safebase-backend/internal/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sshDialTimeout = 15 * time.Second
+
+// ValidateTunnel checks the SSH tunnel settings of db, if it has any.
+func ValidateTunnel(db models.Database) error {
+	if db.SSHHost == "" {
+		return nil
+	}
+	switch {
+	case db.Type == "sqlite":
+		return errors.New("sqlite databases are local files and cannot be reached through an SSH tunnel")
+	case db.ConnectionURI != "":
+		return errors.New("an SSH tunnel cannot be combined with a connection URI")
+	case db.SSHUser == "":
+		return errors.New("an SSH tunnel requires a user")
+	case db.SSHPrivateKey == "" && !db.SSHUseAgent:
+		return errors.New("an SSH tunnel requires a private key or the SSH agent")
+	case db.SSHKnownHosts == "":
+		return errors.New("an SSH tunnel requires the bastion host key, in known_hosts format")
+	}
+	if db.SSHPrivateKey != "" {
+		if _, err := ssh.ParsePrivateKey([]byte(db.SSHPrivateKey)); err != nil {
+			return fmt.Errorf("invalid SSH private key: %v", err)
+		}
+	}
+	return nil
+}
+
+func bastionAddr(db models.Database) string {
+	if _, _, err := net.SplitHostPort(db.SSHHost); err != nil {
+		return net.JoinHostPort(db.SSHHost, "22")
+	}
+	return db.SSHHost
+}
+
+// sshConfig builds the client configuration for the bastion of db. The
+// returned func releases the SSH agent connection, if one was opened.
+func sshConfig(db models.Database) (*ssh.ClientConfig, func(), error) {
+	// knownhosts only reads files; the pinned keys are written to a private one
+	knownHostsFile, removeKnownHosts, err := credentialFile("known_hosts", db.SSHKnownHosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	removeKnownHosts()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid known_hosts: %v", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if db.SSHPrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(db.SSHPrivateKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid SSH private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	release := func() {}
+	if db.SSHUseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, nil, errors.New("SSH agent requested but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to reach the SSH agent: %v", err)
+		}
+		release = func() { conn.Close() }
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	return &ssh.ClientConfig{
+		User:            db.SSHUser,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, release, nil
+}
+
+// openTunnel connects to the bastion of db and forwards a local port to the
+// database. It returns db rewritten to reach the database through that port
+// and a func closing the tunnel, to call once the tools have exited. The
+// rewritten database keeps its SSH settings, so that it is not mistaken for
+// a local one, and must not be tunneled again. A database without SSH
+// settings is returned unchanged.
+func openTunnel(db models.Database) (models.Database, func(), error) {
+	if db.SSHHost == "" {
+		return db, func() {}, nil
+	}
+
+	config, release, err := sshConfig(db)
+	if err != nil {
+		return db, nil, err
+	}
+	client, err := ssh.Dial("tcp", bastionAddr(db), config)
+	release()
+	if err != nil {
+		return db, nil, fmt.Errorf("SSH connection to %s failed: %v", db.SSHHost, err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return db, nil, err
+	}
+
+	// The database host is resolved by the bastion, so localhost is the
+	// bastion itself
+	target := net.JoinHostPort(db.Host, strconv.Itoa(db.Port))
+	var forwards sync.WaitGroup
+	go func() {
+		for {
+			local, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			forwards.Add(1)
+			go func() {
+				defer forwards.Done()
+				forward(client, local, target)
+			}()
+		}
+	}()
+
+	tunneled := db
+	tunneled.Host = "127.0.0.1"
+	tunneled.Port = listener.Addr().(*net.TCPAddr).Port
+
+	closeTunnel := func() {
+		listener.Close()
+		client.Close()
+		forwards.Wait()
+	}
+	return tunneled, closeTunnel, nil
+}
+
+// forward copies a local connection to target through the bastion, both
+// ways, until either side closes.
+func forward(client *ssh.Client, local net.Conn, target string) {
+	defer local.Close()
+	remote, err := client.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
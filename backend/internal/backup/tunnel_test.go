@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"safebase-backend/internal/models"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newSigner(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func listen(t *testing.T, serve func(net.Conn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// startBastion runs an SSH server accepting the client key and forwarding
+// direct-tcpip channels, like sshd with AllowTcpForwarding.
+func startBastion(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) string {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	return listen(t, func(conn net.Conn) {
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for newChannel := range chans {
+			var target struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if newChannel.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChannel.ExtraData(), &target) != nil {
+				newChannel.Reject(ssh.Prohibited, "only direct-tcpip is supported")
+				continue
+			}
+			remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			channel, channelReqs, err := newChannel.Accept()
+			if err != nil {
+				remote.Close()
+				continue
+			}
+			go ssh.DiscardRequests(channelReqs)
+			go func() {
+				defer channel.Close()
+				defer remote.Close()
+				go io.Copy(remote, channel)
+				io.Copy(channel, remote)
+			}()
+		}
+	})
+}
+
+func TestTunnelForwardsThroughBastion(t *testing.T) {
+	hostKey, _ := newSigner(t)
+	clientKey, clientPEM := newSigner(t)
+	bastion := startBastion(t, hostKey, clientKey.PublicKey())
+
+	// The "database" echoes lines back
+	dbAddr := listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		io.Copy(conn, conn)
+	})
+	dbHost, dbPort, _ := net.SplitHostPort(dbAddr)
+	port, _ := strconv.Atoi(dbPort)
+
+	db := models.Database{
+		Type:          "postgresql",
+		Host:          dbHost,
+		Port:          port,
+		SSHHost:       bastion,
+		SSHUser:       "backup",
+		SSHPrivateKey: string(clientPEM),
+		SSHKnownHosts: knownhosts.Line([]string{bastion}, hostKey.PublicKey()),
+	}
+	if err := ValidateTunnel(db); err != nil {
+		t.Fatal(err)
+	}
+
+	tunneled, closeTunnel, err := openTunnel(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tunneled.Host != "127.0.0.1" || tunneled.Port == port {
+		t.Fatalf("Expected the database rewritten to a local port, got %s:%d", tunneled.Host, tunneled.Port)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(tunneled.Host, strconv.Itoa(tunneled.Port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("SELECT 1\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "SELECT 1\n" {
+		t.Fatalf("Expected the query echoed through the tunnel, got %q, %v", line, err)
+	}
+	conn.Close()
+
+	closeTunnel()
+	if _, err := net.Dial("tcp", net.JoinHostPort(tunneled.Host, strconv.Itoa(tunneled.Port))); err == nil {
+		t.Error("Expected the local port to be closed with the tunnel")
+	}
+
+	// A bastion presenting another host key is refused
+	otherKey, _ := newSigner(t)
+	db.SSHKnownHosts = knownhosts.Line([]string{bastion}, otherKey.PublicKey())
+	if _, _, err := openTunnel(db); err == nil {
+		t.Error("Expected an unpinned host key to be rejected")
+	}
+}
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"safebase-backend/internal/models"
+	"strings"
+)
+
+// CorruptionError reports a stored artifact that is missing, truncated or
+// no longer matches what was written.
+type CorruptionError struct {
+	Reason string
+}
+
+func (e *CorruptionError) Error() string {
+	return "backup is corrupted: " + e.Reason
+}
+
+func corrupted(format string, args ...interface{}) error {
+	return &CorruptionError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Verify reads the primary copy of backup back from storage, checks its size
+// and checksum, and decodes it to check the dump structure. It returns a
+// *CorruptionError when the artifact is damaged; other errors mean the check
+// could not run.
+func (be *BackupExecutor) Verify(backup models.Backup) error {
+	if backup.FilePath == "" {
+		return fmt.Errorf("backup %s has no artifact (status: %s)", backup.ID, backup.Status)
+	}
+
+	dumpName := strings.TrimSuffix(backup.FilePath, encryptedExtension)
+	dumpName = strings.TrimSuffix(dumpName, compressionExtension(backup.Compression))
+	driver, err := driverForArtifact(dumpName)
+	if err != nil {
+		return err
+	}
+
+	var dataKey []byte
+	if backup.EncryptionKeyID != "" {
+		if dataKey, err = be.dataKey(backup); err != nil {
+			return err
+		}
+	}
+
+	store, key, err := be.locate(backup)
+	if err != nil {
+		return err
+	}
+	artifact, err := store.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return corrupted("artifact %s is missing", backup.FilePath)
+		}
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	defer artifact.Close()
+
+	hash := sha256.New()
+	stored := &countingWriter{w: hash}
+	tee := io.TeeReader(artifact, stored)
+	input := tee
+
+	if dataKey != nil {
+		if input, err = newDecryptReader(input, dataKey); err != nil {
+			return corrupted("%v", err)
+		}
+	}
+
+	reader, err := newDecompressReader(input, backup.Compression)
+	if err != nil {
+		return corrupted("%v", err)
+	}
+	defer reader.Close()
+
+	if verifier, ok := driver.(Verifier); ok {
+		if err := verifier.Verify(reader); err != nil {
+			return corrupted("%v", err)
+		}
+	}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return corrupted("%v", err)
+	}
+	// Whatever the decoders did not need still counts towards the checksum
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+
+	if backup.SizeBytes > 0 && stored.n != backup.SizeBytes {
+		return corrupted("size is %d bytes, expected %d", stored.n, backup.SizeBytes)
+	}
+	if backup.Checksum != "" {
+		if sum := hex.EncodeToString(hash.Sum(nil)); sum != backup.Checksum {
+			return corrupted("checksum mismatch (got %s, expected %s)", sum, backup.Checksum)
+		}
+	}
+	return nil
+}
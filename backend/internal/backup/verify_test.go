@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "source.db")
+
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	be := NewBackupExecutor(filepath.Join(dir, "backups"))
+	be.SQLiteRoot = dir
+	db := models.Database{ID: "db1", Name: "items", Type: "sqlite", Host: dbPath}
+	b, err := be.ExecuteBackup(context.Background(), db, Options{Compression: Compression{Algorithm: CompressionGzip}})
+	if err != nil {
+		t.Fatalf("ExecuteBackup failed: %v", err)
+	}
+	if len(b.Checksum) != 64 {
+		t.Fatalf("Expected a SHA-256 checksum, got %q", b.Checksum)
+	}
+
+	if err := be.Verify(b); err != nil {
+		t.Fatalf("Expected intact backup to verify, got %v", err)
+	}
+
+	_, key, err := be.locate(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact := filepath.Join(be.BackupDir, key)
+	if err := os.Truncate(artifact, b.SizeBytes/2); err != nil {
+		t.Fatal(err)
+	}
+
+	var corruption *CorruptionError
+	if err := be.Verify(b); !errors.As(err, &corruption) {
+		t.Errorf("Expected CorruptionError for truncated artifact, got %v", err)
+	}
+
+	if err := os.Remove(artifact); err != nil {
+		t.Fatal(err)
+	}
+	if err := be.Verify(b); !errors.As(err, &corruption) || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected CorruptionError for missing artifact, got %v", err)
+	}
+}
+
+func TestMySQLVerifyTrailer(t *testing.T) {
+	dump := "CREATE TABLE t (id INT);\n" + strings.Repeat("INSERT INTO t VALUES (1);\n", 5000)
+
+	if err := (mysqlDriver{}).Verify(strings.NewReader(dump + "-- Dump completed on 2025-03-15 12:00:00\n")); err != nil {
+		t.Errorf("Expected complete dump to verify, got %v", err)
+	}
+	if err := (mysqlDriver{}).Verify(strings.NewReader(dump)); err == nil {
+		t.Error("Expected truncated dump to be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"safebase-backend/internal/keyring"
+	"strings"
+
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// sealedPrefix marks a sealed column value; values without it were
+	// stored in plaintext before credentials were sealed
+	sealedPrefix = "sealed:"
+	// unboundAAD is what credentials were sealed with before they were
+	// bound to their row
+	unboundAAD = "safebase-credential"
+)
+
+// credentialAAD binds a sealed credential to its table, column and row, so
+// that it cannot be copied into another row and opened there, nor opened as
+// anything else sealed with the same keys.
+func credentialAAD(table, column, id string) []byte {
+	return []byte(unboundAAD + ":" + table + "." + column + ":" + id)
+}
+
+var credentialKeys *keyring.Keyring
+
+func init() {
+	// Fields tagged serializer:sealed, such as Database.Password, are sealed
+	// on every write and opened on every read
+	schema.RegisterSerializer("sealed", sealedSerializer{})
+}
+
+// SetCredentialKeys sets the keys sealing stored credentials. It must be
+// called before any credential is read or written.
+func SetCredentialKeys(keys *keyring.Keyring) {
+	credentialKeys = keys
+}
+
+func sealCredential(secret string, aad []byte) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	if credentialKeys == nil {
+		return "", errors.New("no credential keys configured")
+	}
+	sealed, err := credentialKeys.Seal([]byte(secret), aad)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + sealed, nil
+}
+
+// openCredential returns the secret in a stored value and the ID of the key
+// it is sealed with. The ID is empty for a value that still has to be
+// sealed for its row: a plaintext value, or one sealed before credentials
+// were bound to their row.
+func openCredential(stored string, aad []byte) (string, string, error) {
+	sealed, ok := strings.CutPrefix(stored, sealedPrefix)
+	if !ok {
+		return stored, "", nil
+	}
+	if credentialKeys == nil {
+		return "", "", errors.New("no credential keys configured")
+	}
+	secret, keyID, err := credentialKeys.Open(sealed, aad)
+	if err == nil {
+		return string(secret), keyID, nil
+	}
+	if secret, _, unboundErr := credentialKeys.Open(sealed, []byte(unboundAAD)); unboundErr == nil {
+		return string(secret), "", nil
+	}
+	return "", "", err
+}
+
+type sealedSerializer struct{}
+
+// fieldAAD returns the credentialAAD of field in the row dst.
+func fieldAAD(ctx context.Context, field *schema.Field, dst reflect.Value) ([]byte, error) {
+	id, _ := field.Schema.PrioritizedPrimaryField.ValueOf(ctx, dst)
+	if s, ok := id.(string); ok && s != "" {
+		return credentialAAD(field.Schema.Table, field.DBName, s), nil
+	}
+	return nil, fmt.Errorf("%s can only be sealed within a row with an ID", field.Name)
+}
+
+func (sealedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("unexpected %T for sealed field %s", dbValue, field.Name)
+	}
+
+	if stored == "" {
+		return field.Set(ctx, dst, "")
+	}
+	aad, err := fieldAAD(ctx, field, dst)
+	if err != nil {
+		return err
+	}
+	secret, _, err := openCredential(stored, aad)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", field.Name, err)
+	}
+	return field.Set(ctx, dst, secret)
+}
+
+func (sealedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	secret, _ := fieldValue.(string)
+	if secret == "" {
+		return "", nil
+	}
+	aad, err := fieldAAD(ctx, field, dst)
+	if err != nil {
+		return nil, err
+	}
+	return sealCredential(secret, aad)
+}
+
+// sealedColumns lists the sealed columns of each table.
+var sealedColumns = map[string][]string{
+	"databases":       {"password", "connection_uri", "ssh_private_key"},
+	"storage_targets": {"secret_key", "password", "private_key"},
+}
+
+// RekeyCredentials seals the stored credentials not yet sealed with the
+// primary credential key for their row: plaintext values from before
+// credentials were sealed, values sealed before they were bound to their
+// row and, with all set, values sealed with an older key. It returns the
+// number of values sealed again.
+func RekeyCredentials(all bool) (int, error) {
+	if credentialKeys == nil {
+		return 0, errors.New("no credential keys configured")
+	}
+
+	rekeyed := 0
+	for table, columns := range sealedColumns {
+		for _, column := range columns {
+			var rows []struct {
+				ID    string
+				Value string
+			}
+			err := DB.Table(table).Select("id, " + column + " AS value").Where(column + " <> ''").Scan(&rows).Error
+			if err != nil {
+				return rekeyed, err
+			}
+
+			for _, row := range rows {
+				aad := credentialAAD(table, column, row.ID)
+				secret, keyID, err := openCredential(row.Value, aad)
+				if err != nil {
+					return rekeyed, fmt.Errorf("%s %s: %v", table, row.ID, err)
+				}
+				if keyID == credentialKeys.PrimaryID() || (keyID != "" && !all) {
+					continue
+				}
+
+				sealed, err := sealCredential(secret, aad)
+				if err != nil {
+					return rekeyed, err
+				}
+				if err := DB.Table(table).Where("id = ?", row.ID).UpdateColumn(column, sealed).Error; err != nil {
+					return rekeyed, err
+				}
+				rekeyed++
+			}
+		}
+	}
+	return rekeyed, nil
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"safebase-backend/internal/keyring"
+	"safebase-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func testKeys(t *testing.T, spec string) *keyring.Keyring {
+	t.Helper()
+	keys, err := keyring.Parse(spec, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func storedPassword(t *testing.T, id string) string {
+	t.Helper()
+	var stored string
+	if err := DB.Table("databases").Select("password").Where("id = ?", id).Scan(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	return stored
+}
+
+func TestCredentialsAreSealed(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "safebase.db")); err != nil {
+		t.Fatal(err)
+	}
+	key1 := "k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	key2 := "k2:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	SetCredentialKeys(testKeys(t, key1))
+	defer SetCredentialKeys(nil)
+
+	if err := DB.Create(&models.Database{ID: "prod", Name: "prod", Password: "s3cret"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored := storedPassword(t, "prod"); !strings.HasPrefix(stored, "sealed:k1:") {
+		t.Fatalf("Expected the password to be sealed with k1, got %q", stored)
+	}
+	var db models.Database
+	DB.First(&db, "id = ?", "prod")
+	if db.Password != "s3cret" {
+		t.Fatalf("Expected the password to be opened on read, got %q", db.Password)
+	}
+
+	// A row from before credentials were sealed is sealed on start
+	DB.Exec("INSERT INTO databases (id, name, type, host, port, username, password, database) VALUES ('old', 'old', '', '', 0, '', 'legacy', '')")
+	if n, err := RekeyCredentials(false); n != 1 || err != nil {
+		t.Fatalf("Expected the plaintext password to be sealed, got %d, %v", n, err)
+	}
+
+	// After a rotation both rows move to the new primary key
+	SetCredentialKeys(testKeys(t, key1+","+key2))
+	if n, err := RekeyCredentials(true); n != 2 || err != nil {
+		t.Fatalf("Expected both passwords to be re-sealed, got %d, %v", n, err)
+	}
+	SetCredentialKeys(testKeys(t, key2))
+	var old models.Database
+	DB.First(&old, "id = ?", "old")
+	if old.Password != "legacy" || !strings.HasPrefix(storedPassword(t, "old"), "sealed:k2:") {
+		t.Errorf("Expected the legacy password sealed with k2, got %q", storedPassword(t, "old"))
+	}
+}
+
+func TestSealedCredentialsAreBoundToTheirRow(t *testing.T) {
+	if err := InitDB(filepath.Join(t.TempDir(), "safebase.db")); err != nil {
+		t.Fatal(err)
+	}
+	key := "k1:" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	SetCredentialKeys(testKeys(t, key))
+	defer SetCredentialKeys(nil)
+
+	target := models.StorageTarget{ID: "s3", Name: "s3", Type: "s3", SecretKey: "aws-secret", Password: "sftp-pass"}
+	if err := DB.Create(&target).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&models.Database{ID: "prod", Name: "prod", Password: "s3cret"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var stored string
+	DB.Table("storage_targets").Select("secret_key").Where("id = ?", "s3").Scan(&stored)
+	if !strings.HasPrefix(stored, "sealed:k1:") {
+		t.Fatalf("Expected the storage secret key to be sealed, got %q", stored)
+	}
+	var opened models.StorageTarget
+	DB.First(&opened, "id = ?", "s3")
+	if opened.SecretKey != "aws-secret" || opened.Password != "sftp-pass" {
+		t.Fatalf("Expected the storage credentials to be opened on read, got %q, %q", opened.SecretKey, opened.Password)
+	}
+
+	// A sealed value copied into another row or column does not open there
+	DB.Create(&models.Database{ID: "attacker", Name: "attacker"})
+	DB.Exec("UPDATE databases SET password = ? WHERE id = ?", storedPassword(t, "prod"), "attacker")
+	var copied models.Database
+	if err := DB.First(&copied, "id = ?", "attacker").Error; err == nil || copied.Password == "s3cret" {
+		t.Error("Expected a password copied from another row not to open")
+	}
+	DB.Exec("UPDATE databases SET password = '' WHERE id = ?", "attacker")
+	DB.Exec("UPDATE storage_targets SET password = ? WHERE id = ?", stored, "s3")
+	opened = models.StorageTarget{}
+	if err := DB.First(&opened, "id = ?", "s3").Error; err == nil || opened.Password == "aws-secret" {
+		t.Error("Expected a secret key copied into another column not to open")
+	}
+	DB.Exec("UPDATE storage_targets SET password = '' WHERE id = ?", "s3")
+
+	// Values sealed before credentials were bound to their row are sealed
+	// again for it
+	unbound, err := credentialKeys.Seal([]byte("legacy"), []byte(unboundAAD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	DB.Exec("UPDATE databases SET password = ? WHERE id = ?", sealedPrefix+unbound, "attacker")
+	if n, err := RekeyCredentials(false); n != 1 || err != nil {
+		t.Fatalf("Expected the unbound password to be sealed again, got %d, %v", n, err)
+	}
+	copied = models.Database{}
+	if err := DB.First(&copied, "id = ?", "attacker").Error; err != nil || copied.Password != "legacy" {
+		t.Errorf("Expected the legacy password to open, got %q, %v", copied.Password, err)
+	}
+}
@@ -1,9 +1,14 @@
 package database
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"safebase-backend/internal/models"
+	"safebase-backend/internal/storage"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -12,43 +17,99 @@ var DB *gorm.DB
 
 func InitDB(path string) error {
 	var err error
-	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{})
+	// TranslateError reports unique key violations as gorm.ErrDuplicatedKey
+	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return err
 	}
 
-	err = DB.AutoMigrate(&models.User{}, &models.Database{}, &models.BackupSchedule{}, &models.Backup{}, &models.Alert{})
+	err = DB.AutoMigrate(&models.User{}, &models.Database{}, &models.BackupSchedule{}, &models.Backup{}, &models.BackupReplica{}, &models.Restore{}, &models.RestoreDrill{}, &models.Job{}, &models.ScheduleRun{}, &models.Lease{}, &models.BlackoutWindow{}, &models.StorageTarget{}, &models.Alert{})
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return toUTC()
 }
 
-func GetEnabledSchedules() ([]models.BackupSchedule, error) {
-	var schedules []models.BackupSchedule
-	now := time.Now()
-	err := DB.Where("enabled = ? AND (next_run IS NULL OR next_run <= ?)", true, now).Find(&schedules).Error
-	return schedules, err
+// utcColumns lists the time columns that queries compare with the current
+// time. SQLite compares them as strings, so they are stored in UTC.
+var utcColumns = map[string]string{
+	"backup_schedules": "next_run",
+	"jobs":             "run_after",
+	"backups":          "verified_at",
 }
 
-func GetSchedule(scheduleID string) (*models.BackupSchedule, error) {
-	var schedule models.BackupSchedule
-	err := DB.First(&schedule, "id = ?", scheduleID).Error
-	return &schedule, err
+// toUTC rewrites in UTC the times stored in local time by earlier versions.
+func toUTC() error {
+	for table, column := range utcColumns {
+		var rows []struct {
+			ID    string
+			Value time.Time
+		}
+		err := DB.Table(table).Select("id, " + column + " AS value").Where(column + " IS NOT NULL").Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if _, offset := row.Value.Zone(); offset == 0 {
+				continue
+			}
+			if err := DB.Table(table).Where("id = ?", row.ID).UpdateColumn(column, row.Value.UTC()).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func OpenStorageTarget(targetID string) (storage.Storage, error) {
+	var target models.StorageTarget
+	if err := DB.First(&target, "id = ?", targetID).Error; err != nil {
+		return nil, fmt.Errorf("storage target %s not found", targetID)
+	}
+	return storage.Open(target)
 }
 
 func UpdateScheduleNextRun(scheduleID string, nextRun time.Time) error {
-	return DB.Model(&models.BackupSchedule{}).Where("id = ?", scheduleID).Update("next_run", nextRun).Error
+	return DB.Model(&models.BackupSchedule{}).Where("id = ?", scheduleID).Update("next_run", nextRun.UTC()).Error
 }
 
 func UpdateScheduleLastRun(scheduleID string, lastRun time.Time) error {
 	return DB.Model(&models.BackupSchedule{}).Where("id = ?", scheduleID).Update("last_run", lastRun).Error
 }
 
+// AcquireLease takes the named lease for holder, or renews it, until ttl
+// from now. It returns false while another holder's lease is still valid.
+// Times are stored in UTC so that replicas in different zones compare them
+// alike.
+func AcquireLease(name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	result := DB.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return true, nil
+	}
+
+	err := DB.Create(&models.Lease{Name: name, Holder: holder, ExpiresAt: now.Add(ttl)}).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseLease gives up the named lease if holder has it.
+func ReleaseLease(name, holder string) error {
+	return DB.Model(&models.Lease{}).Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", time.Now().UTC()).Error
+}
+
 func CreateAlert(alertType, title, message, databaseName string) error {
 	alert := models.Alert{
-		ID:           time.Now().Format("20060102150405") + "-" + alertType,
+		ID:           uuid.New().String(),
 		Type:         alertType,
 		Title:        title,
 		Message:      message,
@@ -59,3 +120,51 @@ func CreateAlert(alertType, title, message, databaseName string) error {
 	return DB.Create(&alert).Error
 }
 
+const (
+	selfDatabaseID = "safebase-self"
+	selfScheduleID = "safebase-self-schedule"
+)
+
+// EnsureSelfBackup registers SafeBase's own metadata database as a sqlite
+// source, which it returns, and keeps its backup schedule in sync with
+// cronExpr.
+func EnsureSelfBackup(dbPath, cronExpr string) (models.Database, error) {
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return models.Database{}, err
+	}
+
+	self := models.Database{
+		ID:        selfDatabaseID,
+		Name:      "safebase",
+		Type:      "sqlite",
+		Host:      absPath,
+		Database:  "main",
+		Status:    "connected",
+		CreatedAt: time.Now(),
+	}
+	if err := DB.Where(models.Database{ID: selfDatabaseID}).Attrs(self).FirstOrCreate(&self).Error; err != nil {
+		return self, err
+	}
+	if err := DB.Model(&self).Updates(map[string]interface{}{"host": absPath, "type": "sqlite"}).Error; err != nil {
+		return self, err
+	}
+	self.Host, self.Type = absPath, "sqlite"
+
+	schedule := models.BackupSchedule{
+		ID:             selfScheduleID,
+		DatabaseID:     self.ID,
+		DatabaseName:   self.Name,
+		CronExpression: cronExpr,
+		Enabled:        true,
+		CreatedAt:      time.Now(),
+	}
+	if err := DB.Where(models.BackupSchedule{ID: selfScheduleID}).Attrs(schedule).FirstOrCreate(&schedule).Error; err != nil {
+		return self, err
+	}
+	if schedule.CronExpression == cronExpr {
+		return self, nil
+	}
+	// Clearing NextRun lets the scheduler compute it from the new expression
+	return self, DB.Model(&schedule).Updates(map[string]interface{}{"cron_expression": cronExpr, "next_run": nil}).Error
+}
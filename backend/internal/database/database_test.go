@@ -0,0 +1,49 @@
+package database
+
+import (
+	"path/filepath"
+	"safebase-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestLocalTimesMovedToUTC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "safebase.db")
+	if err := InitDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Written in local time by an earlier version
+	nextRun := time.Date(2025, 3, 15, 2, 0, 0, 0, time.FixedZone("CET", 3600))
+	if err := DB.Create(&models.BackupSchedule{ID: "nightly", NextRun: &nextRun}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&models.Job{ID: "retry", RunAfter: &nextRun}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := DB.Create(&models.Backup{ID: "verified", VerifiedAt: &nextRun}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := InitDB(path); err != nil {
+		t.Fatal(err)
+	}
+	var schedule models.BackupSchedule
+	var job models.Job
+	var b models.Backup
+	DB.First(&schedule, "id = ?", "nightly")
+	DB.First(&job, "id = ?", "retry")
+	DB.First(&b, "id = ?", "verified")
+	for _, stored := range []*time.Time{schedule.NextRun, job.RunAfter, b.VerifiedAt} {
+		if _, offset := stored.Zone(); offset != 0 || !stored.Equal(nextRun) {
+			t.Errorf("Expected %s stored in UTC, got %s", nextRun, stored)
+		}
+	}
+
+	// 01:00 UTC sorts before 01:30 UTC, where 02:00+01:00 did not
+	var due int64
+	DB.Model(&models.BackupSchedule{}).Where("next_run <= ?", time.Date(2025, 3, 15, 1, 30, 0, 0, time.UTC)).Count(&due)
+	if due != 1 {
+		t.Error("Expected the schedule due once compared in UTC")
+	}
+}
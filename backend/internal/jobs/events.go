@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"sync"
+)
+
+const (
+	// EventStatus carries the job itself whenever its status changes
+	EventStatus = "status"
+
+	subscriberBuffer = 256
+	historySize      = 200
+)
+
+// Event is a message about a running job, published by the queue and by job
+// handlers and fanned out to subscribers such as the SSE endpoint.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// Publisher sends an event about the job being run.
+type Publisher func(eventType string, data interface{})
+
+// broker keeps the recent events of each active job, so a subscriber that
+// connects mid-run first receives what it missed.
+type broker struct {
+	mu          sync.Mutex
+	history     map[string][]Event
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{
+		history:     make(map[string][]Event),
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+func (b *broker) publish(jobID string, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history := append(b.history[jobID], event)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+	b.history[jobID] = history
+
+	for ch := range b.subscribers[jobID] {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscribers miss events rather than stall the job
+		}
+	}
+}
+
+// subscribe returns the events published so far and a channel for the next
+// ones. The channel is closed when the job finishes or cancel is called.
+func (b *broker) subscribe(jobID string) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	if b.subscribers[jobID] == nil {
+		b.subscribers[jobID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[jobID][ch] = struct{}{}
+
+	past := append([]Event(nil), b.history[jobID]...)
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[jobID][ch]; ok {
+			delete(b.subscribers[jobID], ch)
+			close(ch)
+		}
+		// Jobs run by another replica are never finished here
+		if len(b.subscribers[jobID]) == 0 {
+			delete(b.subscribers, jobID)
+		}
+	}
+	return past, ch, cancel
+}
+
+// finish closes the subscriptions of a job and forgets its history.
+func (b *broker) finish(jobID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[jobID] {
+		close(ch)
+	}
+	delete(b.subscribers, jobID)
+	delete(b.history, jobID)
+}
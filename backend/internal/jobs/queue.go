@@ -0,0 +1,423 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSuccess   = "success"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+
+	// DefaultWorkers is the number of jobs run at the same time
+	DefaultWorkers = 2
+	// MaxAttempts bounds how often an interrupted job is re-run
+	MaxAttempts = 3
+	// DefaultMaxPerHost lets a single dump run against a database host
+	DefaultMaxPerHost = 1
+	// DefaultLeaseTTL is how long a running job stays held by its replica
+	// without a heartbeat before another replica may take it over
+	DefaultLeaseTTL = 30 * time.Second
+
+	pollInterval = 5 * time.Second
+	// claimBatch is how many queued jobs a worker looks through for one
+	// within the concurrency limits
+	claimBatch = 50
+)
+
+// Handler runs a job, reporting progress through publish, and must stop
+// when ctx is cancelled. It may set job.ResultID; a returned error fails the
+// job.
+type Handler func(ctx context.Context, job *models.Job, publish Publisher) error
+
+// Queue is a job queue persisted in the metadata database, so queued and
+// interrupted jobs survive a restart. Workers claim jobs in FIFO order.
+//
+// Several replicas may share the queue: a running job is leased to the
+// replica running it, which renews the lease until the job finishes. When a
+// replica dies its leases expire and its jobs are queued again for the
+// others.
+type Queue struct {
+	Workers int
+	// ID identifies this replica as the holder of job leases
+	ID       string
+	LeaseTTL time.Duration
+	// MaxPerHost and MaxPerStorage cap the jobs running at once, across
+	// replicas, against one database host or one storage target; 0 means
+	// no limit. Workers caps them per replica.
+	MaxPerHost    int
+	MaxPerStorage int
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	running  map[string]context.CancelFunc
+	events   *broker
+	wake     chan struct{}
+	stop     chan struct{}
+	workers  sync.WaitGroup
+}
+
+func NewQueue(workers int) *Queue {
+	return &Queue{
+		Workers:    workers,
+		ID:         instanceID(),
+		LeaseTTL:   DefaultLeaseTTL,
+		MaxPerHost: DefaultMaxPerHost,
+		handlers:   make(map[string]Handler),
+		running:    make(map[string]context.CancelFunc),
+		events:     newBroker(),
+		stop:       make(chan struct{}),
+	}
+}
+
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+func (q *Queue) handler(jobType string) (Handler, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	handler, ok := q.handlers[jobType]
+	return handler, ok
+}
+
+// instanceID names this process uniquely, prefixed with the hostname to tell
+// replicas apart in logs.
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "safebase"
+	}
+	return host + "-" + uuid.New().String()[:8]
+}
+
+// Start recovers jobs whose replica stopped renewing their lease, then starts
+// the workers and the lease heartbeat.
+func (q *Queue) Start() {
+	if q.Workers <= 0 {
+		q.Workers = DefaultWorkers
+	}
+	q.wake = make(chan struct{}, q.Workers)
+
+	if err := q.recover(); err != nil {
+		log.Printf("Failed to recover interrupted jobs: %v", err)
+	}
+
+	for i := 0; i < q.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	q.workers.Add(1)
+	go q.heartbeat()
+}
+
+// Stop stops the workers, waiting for the jobs they are running to finish.
+func (q *Queue) Stop() {
+	close(q.stop)
+	q.workers.Wait()
+}
+
+// Enqueue persists job as queued and wakes a worker. A job with RunAfter
+// set waits until then.
+func (q *Queue) Enqueue(job models.Job) (models.Job, error) {
+	job, err := q.EnqueueTx(database.DB, job)
+	if err == nil {
+		q.Notify()
+	}
+	return job, err
+}
+
+// EnqueueTx persists job as queued within tx, so that it is only queued if
+// tx commits. Call Notify once it has.
+func (q *Queue) EnqueueTx(tx *gorm.DB, job models.Job) (models.Job, error) {
+	if _, ok := q.handler(job.Type); !ok {
+		return job, fmt.Errorf("unknown job type: %s", job.Type)
+	}
+
+	job.ID = uuid.New().String()
+	job.Status = StatusQueued
+	if job.RunAfter != nil {
+		// Compared as a string by claim, like every stored time
+		runAfter := job.RunAfter.UTC()
+		job.RunAfter = &runAfter
+	}
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = time.Now()
+	if err := tx.Create(&job).Error; err != nil {
+		return job, err
+	}
+	return job, nil
+}
+
+// Notify wakes a worker to look for queued jobs.
+func (q *Queue) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// recover puts running jobs whose lease has expired, because their replica
+// stopped, back in the queue, or fails them once they have used up their
+// attempts.
+func (q *Queue) recover() error {
+	now := time.Now().UTC()
+	var interrupted []models.Job
+	err := database.DB.Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", StatusRunning, now).
+		Find(&interrupted).Error
+	if err != nil {
+		return err
+	}
+
+	for _, job := range interrupted {
+		updates := map[string]interface{}{"status": StatusQueued, "started_at": nil, "worker_id": "", "lease_until": nil}
+		if job.Attempts >= MaxAttempts {
+			finished := time.Now()
+			updates = map[string]interface{}{
+				"status":      StatusFailed,
+				"error":       fmt.Sprintf("interrupted %d times", job.Attempts),
+				"finished_at": &finished,
+			}
+		}
+		// The lease condition stops two replicas recovering the same job
+		result := database.DB.Model(&models.Job{}).
+			Where("id = ? AND status = ? AND (lease_until IS NULL OR lease_until < ?)", job.ID, StatusRunning, now).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			log.Printf("Recovered interrupted %s job %s from %s (attempt %d)", job.Type, job.ID, job.WorkerID, job.Attempts)
+		}
+	}
+	return nil
+}
+
+// heartbeat renews the leases of the jobs this replica runs and recovers
+// jobs abandoned by other replicas. A job whose lease can no longer be
+// renewed, because it was cancelled elsewhere or taken over, is stopped.
+func (q *Queue) heartbeat() {
+	defer q.workers.Done()
+	ticker := time.NewTicker(q.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-q.stop:
+			return
+		}
+
+		q.mu.RLock()
+		running := make(map[string]context.CancelFunc, len(q.running))
+		for id, cancel := range q.running {
+			running[id] = cancel
+		}
+		q.mu.RUnlock()
+
+		leaseUntil := time.Now().UTC().Add(q.LeaseTTL)
+		for id, cancel := range running {
+			result := database.DB.Model(&models.Job{}).
+				Where("id = ? AND worker_id = ? AND status = ?", id, q.ID, StatusRunning).
+				Update("lease_until", leaseUntil)
+			if result.Error != nil {
+				log.Printf("Failed to renew lease of job %s: %v", id, result.Error)
+				continue
+			}
+			if result.RowsAffected == 0 {
+				cancel()
+			}
+		}
+
+		if err := q.recover(); err != nil {
+			log.Printf("Failed to recover interrupted jobs: %v", err)
+		}
+	}
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for {
+		job, ok := q.claim()
+		if ok {
+			q.run(job)
+			continue
+		}
+
+		select {
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// claim atomically moves the oldest queued job that is due, and within the
+// concurrency limits, to running. Jobs held back by a limit do not block the
+// ones behind them, and being older they take the next free slot first.
+// Several workers may race for the same job; only the one whose update
+// matches wins.
+func (q *Queue) claim() (models.Job, bool) {
+	var candidates []models.Job
+	err := database.DB.Where("status = ? AND (run_after IS NULL OR run_after <= ?)", StatusQueued, time.Now().UTC()).
+		Order("created_at").Limit(claimBatch).Find(&candidates).Error
+	if err != nil {
+		return models.Job{}, false
+	}
+
+	for _, job := range candidates {
+		now := time.Now()
+		leaseUntil := now.UTC().Add(q.LeaseTTL)
+		update := database.DB.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, StatusQueued)
+		// The limits are checked by the update itself, so that replicas
+		// claiming at the same time cannot both take the last slot
+		if q.MaxPerHost > 0 && job.Host != "" {
+			update = update.Where("(SELECT COUNT(*) FROM jobs AS j WHERE j.status = ? AND j.host = ?) < ?", StatusRunning, job.Host, q.MaxPerHost)
+		}
+		if q.MaxPerStorage > 0 && job.Storage != "" {
+			update = update.Where("(SELECT COUNT(*) FROM jobs AS j WHERE j.status = ? AND j.storage = ?) < ?", StatusRunning, job.Storage, q.MaxPerStorage)
+		}
+
+		result := update.Updates(map[string]interface{}{
+			"status":      StatusRunning,
+			"started_at":  &now,
+			"attempts":    job.Attempts + 1,
+			"worker_id":   q.ID,
+			"lease_until": &leaseUntil,
+		})
+		if result.Error != nil {
+			log.Printf("Failed to claim job %s: %v", job.ID, result.Error)
+			return job, false
+		}
+		if result.RowsAffected == 1 {
+			job.Status = StatusRunning
+			job.StartedAt = &now
+			job.Attempts++
+			job.WorkerID = q.ID
+			job.LeaseUntil = &leaseUntil
+			return job, true
+		}
+	}
+	return models.Job{}, false
+}
+
+func (q *Queue) run(job models.Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel()
+	}()
+
+	q.events.publish(job.ID, Event{Type: EventStatus, Data: job})
+	err := q.execute(ctx, &job)
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = StatusSuccess
+	job.Error = ""
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.Canceled):
+		job.Status = StatusCancelled
+		job.Error = "cancelled"
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	}
+
+	// A job taken over by another replica is no longer this one's to record
+	result := database.DB.Model(&models.Job{}).Where("id = ? AND worker_id = ?", job.ID, q.ID).Updates(map[string]interface{}{
+		"status":      job.Status,
+		"error":       job.Error,
+		"result_id":   job.ResultID,
+		"finished_at": job.FinishedAt,
+		"lease_until": nil,
+	})
+	if result.Error != nil {
+		log.Printf("Failed to record result of job %s: %v", job.ID, result.Error)
+	}
+
+	q.events.publish(job.ID, Event{Type: EventStatus, Data: job})
+	q.events.finish(job.ID)
+
+	// The slot this job held may let a job waiting on a limit start
+	q.Notify()
+}
+
+func (q *Queue) execute(ctx context.Context, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	handler, ok := q.handler(job.Type)
+	if !ok {
+		return fmt.Errorf("unknown job type: %s", job.Type)
+	}
+	return handler(ctx, job, func(eventType string, data interface{}) {
+		q.events.publish(job.ID, Event{Type: eventType, Data: data})
+	})
+}
+
+// Subscribe returns the events already published for a running job and a
+// channel receiving the next ones until the job finishes. Call cancel to
+// stop listening early.
+func (q *Queue) Subscribe(jobID string) ([]Event, <-chan Event, func()) {
+	return q.events.subscribe(jobID)
+}
+
+// Cancel stops a job: a queued job is cancelled before it starts, a running
+// one has its context cancelled and is recorded as cancelled once its
+// handler returns. A job running on another replica is stopped there when
+// its lease next fails to renew. It returns false when the job has already
+// finished.
+func (q *Queue) Cancel(jobID string) (bool, error) {
+	q.mu.RLock()
+	cancel, running := q.running[jobID]
+	q.mu.RUnlock()
+	if running {
+		cancel()
+		return true, nil
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.Job{}).Where("id = ? AND status IN ?", jobID, []string{StatusQueued, StatusRunning}).
+		Updates(map[string]interface{}{"status": StatusCancelled, "error": "cancelled", "finished_at": &now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Finished reports whether status is final.
+func Finished(status string) bool {
+	return status == StatusSuccess || status == StatusFailed || status == StatusCancelled
+}
+
+// Get returns a job by ID.
+func Get(id string) (models.Job, error) {
+	var job models.Job
+	err := database.DB.First(&job, "id = ?", id).Error
+	return job, err
+}
@@ -0,0 +1,317 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "safebase.db")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, id string, status string) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := Get(id)
+		if err == nil && job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	job, _ := Get(id)
+	t.Fatalf("Job %s did not reach %s, last status %s", id, status, job.Status)
+	return job
+}
+
+func TestQueueRunsJobs(t *testing.T) {
+	setupDB(t)
+
+	q := NewQueue(2)
+	q.Register("echo", func(ctx context.Context, job *models.Job, publish Publisher) error {
+		if job.DatabaseID == "bad" {
+			return errors.New("boom")
+		}
+		job.ResultID = "result-" + job.DatabaseID
+		return nil
+	})
+	q.Start()
+	defer q.Stop()
+
+	ok, err := q.Enqueue(models.Job{Type: "echo", DatabaseID: "db1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok.Status != StatusQueued {
+		t.Errorf("Expected queued job, got %s", ok.Status)
+	}
+	bad, err := q.Enqueue(models.Job{Type: "echo", DatabaseID: "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := waitFor(t, ok.ID, StatusSuccess)
+	if done.ResultID != "result-db1" || done.Attempts != 1 || done.FinishedAt == nil {
+		t.Errorf("Unexpected finished job: %+v", done)
+	}
+	if failed := waitFor(t, bad.ID, StatusFailed); failed.Error != "boom" {
+		t.Errorf("Expected job error to be recorded, got %q", failed.Error)
+	}
+
+	if _, err := q.Enqueue(models.Job{Type: "unknown"}); err == nil {
+		t.Error("Expected unknown job type to be rejected")
+	}
+}
+
+func TestQueueRecoversInterruptedJobs(t *testing.T) {
+	setupDB(t)
+
+	started := time.Now()
+	interrupted := models.Job{ID: "interrupted", Type: "echo", Status: StatusRunning, Attempts: 1, StartedAt: &started}
+	exhausted := models.Job{ID: "exhausted", Type: "echo", Status: StatusRunning, Attempts: MaxAttempts, StartedAt: &started}
+	for _, job := range []models.Job{interrupted, exhausted} {
+		if err := database.DB.Create(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := NewQueue(1)
+	q.Register("echo", func(ctx context.Context, job *models.Job, publish Publisher) error { return nil })
+	q.Start()
+	defer q.Stop()
+
+	if job := waitFor(t, "interrupted", StatusSuccess); job.Attempts != 2 {
+		t.Errorf("Expected the recovered job to run a second time, got %d attempts", job.Attempts)
+	}
+	waitFor(t, "exhausted", StatusFailed)
+}
+
+func TestQueuePublishesEvents(t *testing.T) {
+	setupDB(t)
+
+	release := make(chan struct{})
+	q := NewQueue(1)
+	q.Register("echo", func(ctx context.Context, job *models.Job, publish Publisher) error {
+		publish("phase", "dumping")
+		<-release
+		publish("phase", "uploading")
+		return nil
+	})
+
+	job, err := q.Enqueue(models.Job{Type: "echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Stop()
+
+	waitFor(t, job.ID, StatusRunning)
+	past, events, cancel := q.Subscribe(job.ID)
+	defer cancel()
+	close(release)
+
+	var types []string
+	for _, event := range past {
+		types = append(types, event.Type)
+	}
+	for event := range events {
+		types = append(types, event.Type)
+	}
+
+	// status (running), both phases, then the final status; the channel
+	// is closed once the job finishes
+	if len(types) != 4 || types[0] != EventStatus || types[3] != EventStatus {
+		t.Errorf("Unexpected events: %v", types)
+	}
+}
+
+func TestQueueCancelsJobs(t *testing.T) {
+	setupDB(t)
+
+	q := NewQueue(1)
+	q.Register("block", func(ctx context.Context, job *models.Job, publish Publisher) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	running, err := q.Enqueue(models.Job{Type: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := q.Enqueue(models.Job{Type: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Stop()
+
+	waitFor(t, running.ID, StatusRunning)
+	if ok, err := q.Cancel(queued.ID); !ok || err != nil {
+		t.Fatalf("Expected queued job to be cancelled, got %v, %v", ok, err)
+	}
+	if ok, err := q.Cancel(running.ID); !ok || err != nil {
+		t.Fatalf("Expected running job to be cancelled, got %v, %v", ok, err)
+	}
+
+	waitFor(t, running.ID, StatusCancelled)
+	waitFor(t, queued.ID, StatusCancelled)
+	if ok, _ := q.Cancel(running.ID); ok {
+		t.Error("Expected a finished job not to be cancellable")
+	}
+}
+
+func TestQueueWaitsForRunAfter(t *testing.T) {
+	setupDB(t)
+
+	q := NewQueue(1)
+	q.Register("noop", func(ctx context.Context, job *models.Job, publish Publisher) error { return nil })
+
+	later := time.Now().Add(time.Hour)
+	delayed, err := q.Enqueue(models.Job{Type: "noop", RunAfter: &later})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due, err := q.Enqueue(models.Job{Type: "noop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Stop()
+
+	waitFor(t, due.ID, StatusSuccess)
+	if job, _ := Get(delayed.ID); job.Status != StatusQueued {
+		t.Errorf("Expected the delayed job to wait, got %s", job.Status)
+	}
+}
+
+func TestQueueTakesOverExpiredLeases(t *testing.T) {
+	setupDB(t)
+
+	started := time.Now()
+	expired := started.UTC().Add(-time.Second)
+	held := started.UTC().Add(time.Hour)
+	abandoned := models.Job{ID: "abandoned", Type: "echo", Status: StatusRunning, Attempts: 1, StartedAt: &started, WorkerID: "dead", LeaseUntil: &expired}
+	alive := models.Job{ID: "alive", Type: "echo", Status: StatusRunning, Attempts: 1, StartedAt: &started, WorkerID: "other", LeaseUntil: &held}
+	for _, job := range []models.Job{abandoned, alive} {
+		if err := database.DB.Create(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := NewQueue(1)
+	q.LeaseTTL = 300 * time.Millisecond
+	q.Register("echo", func(ctx context.Context, job *models.Job, publish Publisher) error { return nil })
+	q.Start()
+	defer q.Stop()
+
+	if job := waitFor(t, "abandoned", StatusSuccess); job.WorkerID != q.ID {
+		t.Errorf("Expected the job to be taken over by %s, got %s", q.ID, job.WorkerID)
+	}
+	// Let a few heartbeats pass: a job whose replica renews its lease stays put
+	time.Sleep(2 * q.LeaseTTL)
+	if job, _ := Get("alive"); job.Status != StatusRunning || job.WorkerID != "other" {
+		t.Errorf("Expected the leased job to stay with its replica, got %s on %s", job.Status, job.WorkerID)
+	}
+}
+
+func TestQueueStopsJobsCancelledElsewhere(t *testing.T) {
+	setupDB(t)
+
+	stopped := make(chan struct{})
+	q := NewQueue(1)
+	q.LeaseTTL = 300 * time.Millisecond
+	q.Register("block", func(ctx context.Context, job *models.Job, publish Publisher) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	job, err := q.Enqueue(models.Job{Type: "block"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.Start()
+	defer q.Stop()
+	waitFor(t, job.ID, StatusRunning)
+
+	// Another replica's queue does not run the job but can cancel it
+	other := NewQueue(1)
+	if ok, err := other.Cancel(job.ID); !ok || err != nil {
+		t.Fatalf("Expected the running job to be cancelled, got %v, %v", ok, err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the next heartbeat to stop the job")
+	}
+	waitFor(t, job.ID, StatusCancelled)
+}
+
+func TestQueueConcurrencyLimits(t *testing.T) {
+	setupDB(t)
+
+	release := make(chan struct{})
+	q := NewQueue(3)
+	q.MaxPerHost = 1
+	q.MaxPerStorage = 2
+	q.Register("dump", func(ctx context.Context, job *models.Job, publish Publisher) error {
+		<-release
+		return nil
+	})
+
+	var queued []models.Job
+	for _, job := range []models.Job{
+		{Type: "dump", Host: "mysql:3306", Storage: "s3"},
+		{Type: "dump", Host: "mysql:3306", Storage: "s3"},
+		{Type: "dump", Host: "pg:5432", Storage: "s3"},
+		{Type: "dump", Host: "mongo:27017", Storage: "s3"},
+	} {
+		job, err := q.Enqueue(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queued = append(queued, job)
+	}
+	q.Start()
+	defer q.Stop()
+
+	// The second mysql job waits for the host, without holding back the
+	// pg job behind it; the mongo job waits for the storage target
+	waitFor(t, queued[0].ID, StatusRunning)
+	waitFor(t, queued[2].ID, StatusRunning)
+	time.Sleep(100 * time.Millisecond)
+	for _, job := range []models.Job{queued[1], queued[3]} {
+		if job, _ := Get(job.ID); job.Status != StatusQueued {
+			t.Errorf("Expected job %s to wait for a slot, got %s", job.ID, job.Status)
+		}
+	}
+
+	close(release)
+	for _, job := range queued {
+		waitFor(t, job.ID, StatusSuccess)
+	}
+}
+
+func TestUnsubscribeForgetsRemoteJobs(t *testing.T) {
+	q := NewQueue(1)
+
+	// A job running on another replica never finishes here
+	_, _, cancel1 := q.Subscribe("remote")
+	_, _, cancel2 := q.Subscribe("remote")
+	cancel1()
+	if len(q.events.subscribers["remote"]) != 1 {
+		t.Fatalf("Expected one subscriber left, got %d", len(q.events.subscribers["remote"]))
+	}
+	cancel2()
+	if _, ok := q.events.subscribers["remote"]; ok {
+		t.Error("Expected the job to be forgotten once its last subscriber left")
+	}
+}
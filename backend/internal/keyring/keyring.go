@@ -0,0 +1,214 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Keyring holds the master keys used to wrap per-backup data keys. New data
+// keys are always wrapped with the primary key; the other keys are kept so
+// artifacts written before a rotation can still be opened.
+type Keyring struct {
+	keys    map[string][]byte
+	primary string
+}
+
+// Parse reads keys in the form "id:base64key", separated by commas or
+// newlines. The last key listed is primary unless primaryID is set.
+func Parse(spec, primaryID string) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte)}
+
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry, expected id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %v", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+		}
+
+		kr.keys[id] = key
+		kr.primary = id
+	}
+
+	if len(kr.keys) == 0 {
+		return nil, fmt.Errorf("no keys found")
+	}
+	if primaryID != "" {
+		if _, ok := kr.keys[primaryID]; !ok {
+			return nil, fmt.Errorf("primary key %s not found", primaryID)
+		}
+		kr.primary = primaryID
+	}
+
+	return kr, nil
+}
+
+// LoadFromEnv builds the keyring from BACKUP_ENCRYPTION_KEYS or the file
+// named by BACKUP_ENCRYPTION_KEYS_FILE. It returns nil when neither is set,
+// which disables encryption.
+func LoadFromEnv() (*Keyring, error) {
+	return loadEnv("BACKUP_ENCRYPTION")
+}
+
+// LoadCredentialKeys builds the keyring sealing stored database credentials
+// from CREDENTIAL_KEYS or CREDENTIAL_KEYS_FILE. When neither is set a key is
+// generated on first start and kept in defaultPath, readable by the server
+// only.
+func LoadCredentialKeys(defaultPath string) (*Keyring, error) {
+	kr, err := loadEnv("CREDENTIAL")
+	if kr != nil || err != nil {
+		return kr, err
+	}
+
+	content, err := os.ReadFile(defaultPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		content = []byte("default:" + base64.StdEncoding.EncodeToString(key) + "\n")
+		err = os.WriteFile(defaultPath, content, 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(content), "")
+}
+
+// loadEnv reads <prefix>_KEYS, <prefix>_KEYS_FILE and <prefix>_PRIMARY_KEY.
+func loadEnv(prefix string) (*Keyring, error) {
+	spec := os.Getenv(prefix + "_KEYS")
+	if path := os.Getenv(prefix + "_KEYS_FILE"); path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		spec = string(content)
+	}
+
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	return Parse(spec, os.Getenv(prefix+"_PRIMARY_KEY"))
+}
+
+func (kr *Keyring) PrimaryID() string {
+	return kr.primary
+}
+
+// NewDataKey generates a random 256-bit data key and returns it together
+// with its wrapped form. aad binds the wrapped key to its owner, typically
+// the backup ID.
+func (kr *Keyring) NewDataKey(aad []byte) ([]byte, string, []byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", nil, err
+	}
+
+	wrapped, err := kr.wrap(kr.primary, dataKey, aad)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return dataKey, kr.primary, wrapped, nil
+}
+
+func (kr *Keyring) Unwrap(keyID string, wrapped, aad []byte) ([]byte, error) {
+	gcm, err := kr.cipher(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	nonce, ciphertext := wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():]
+	dataKey, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with key %s: %v", keyID, err)
+	}
+	return dataKey, nil
+}
+
+// Rewrap re-encrypts a wrapped data key under the primary key. The data key
+// itself, and therefore the artifact it protects, is unchanged.
+func (kr *Keyring) Rewrap(keyID string, wrapped, aad []byte) (string, []byte, error) {
+	dataKey, err := kr.Unwrap(keyID, wrapped, aad)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rewrapped, err := kr.wrap(kr.primary, dataKey, aad)
+	if err != nil {
+		return "", nil, err
+	}
+	return kr.primary, rewrapped, nil
+}
+
+// Seal encrypts a small secret, such as a password, with the primary key.
+// The result starts with the key ID so it can still be opened once another
+// key becomes primary.
+func (kr *Keyring) Seal(secret, aad []byte) (string, error) {
+	sealed, err := kr.wrap(kr.primary, secret, aad)
+	if err != nil {
+		return "", err
+	}
+	return kr.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a secret sealed by Seal and reports the ID of its key.
+func (kr *Keyring) Open(sealed string, aad []byte) ([]byte, string, error) {
+	keyID, encoded, ok := strings.Cut(sealed, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid sealed secret")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid sealed secret: %v", err)
+	}
+	secret, err := kr.Unwrap(keyID, wrapped, aad)
+	return secret, keyID, err
+}
+
+func (kr *Keyring) wrap(keyID string, dataKey, aad []byte) ([]byte, error) {
+	gcm, err := kr.cipher(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, aad), nil
+}
+
+func (kr *Keyring) cipher(keyID string) (cipher.AEAD, error) {
+	key, ok := kr.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key: %s", keyID)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
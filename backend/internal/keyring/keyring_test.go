@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestRewrapKeepsDataKey(t *testing.T) {
+	old, err := Parse("2024:"+testKey(1), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataKey, keyID, wrapped, err := old.NewDataKey([]byte("backup-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "2024" {
+		t.Errorf("Expected key ID '2024', got '%s'", keyID)
+	}
+
+	rotated, err := Parse("2024:"+testKey(1)+",2025:"+testKey(2), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newID, rewrapped, err := rotated.Rewrap(keyID, wrapped, []byte("backup-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newID != "2025" {
+		t.Errorf("Expected key ID '2025', got '%s'", newID)
+	}
+
+	unwrapped, err := rotated.Unwrap(newID, rewrapped, []byte("backup-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Error("Expected re-wrapped data key to be unchanged")
+	}
+
+	if _, err := rotated.Unwrap(newID, rewrapped, []byte("backup-2")); err == nil {
+		t.Error("Expected unwrap to fail for a different backup ID")
+	}
+}
+
+func TestParseRejectsShortKey(t *testing.T) {
+	if _, err := Parse("k1:"+base64.StdEncoding.EncodeToString([]byte("short")), ""); err == nil {
+		t.Error("Expected error for short key")
+	}
+}
@@ -14,46 +14,317 @@ type User struct {
 }
 
 type Database struct {
-	ID          string    `gorm:"primaryKey" json:"id"`
-	Name        string    `gorm:"not null" json:"name"`
-	Type        string    `gorm:"not null" json:"type"`
-	Host        string    `gorm:"not null" json:"host"`
-	Port        int       `gorm:"not null" json:"port"`
-	Username    string    `gorm:"not null" json:"username"`
-	Password    string    `gorm:"not null" json:"password"`
-	Database    string    `gorm:"not null" json:"database"`
-	Status      string    `json:"status"`
+	ID          string     `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"not null" json:"name"`
+	Type        string     `gorm:"not null" json:"type"`
+	Host        string     `gorm:"not null" json:"host"`
+	Port        int        `gorm:"not null" json:"port"`
+	Username    string     `gorm:"not null" json:"username"`
+	Password    string     `gorm:"not null;serializer:sealed" json:"password,omitempty"`
+	Database    string     `gorm:"not null" json:"database"`
+	Status      string     `json:"status"`
 	LastBackup  *time.Time `json:"lastBackup"`
-	BackupCount int       `json:"backupCount"`
-	Size        string    `json:"size"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	BackupCount int        `json:"backupCount"`
+	Size        string     `json:"size"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+
+	// Secret holding the password instead of Password, resolved at backup
+	// time: env:NAME, file:/path or vault:path#field
+	PasswordRef string `json:"passwordRef,omitempty"`
+
+	// SSH tunnel through a bastion host[:port]; empty means the database is
+	// reached directly. The bastion key is pinned in known_hosts format and
+	// the user authenticates with SSHPrivateKey or the server's SSH agent.
+	SSHHost       string `json:"sshHost,omitempty"`
+	SSHUser       string `json:"sshUser,omitempty"`
+	SSHPrivateKey string `gorm:"serializer:sealed" json:"sshPrivateKey,omitempty"`
+	SSHUseAgent   bool   `json:"sshUseAgent"`
+	SSHKnownHosts string `json:"sshKnownHosts,omitempty"`
+
+	// MongoDB connection options
+	AuthDatabase   string `json:"authDatabase,omitempty"`
+	ConnectionURI  string `gorm:"serializer:sealed" json:"connectionUri,omitempty"`
+	ReadPreference string `json:"readPreference,omitempty"`
+
+	// Default compression for backups of this database (none, gzip, zstd)
+	Compression      string `gorm:"default:none" json:"compression"`
+	CompressionLevel int    `json:"compressionLevel"`
+
+	// Where backups are stored; empty means the server's BACKUP_DIR
+	StorageTargetID string `json:"storageTargetId,omitempty"`
+
+	// Backups running longer are killed; 0 means no limit
+	TimeoutMinutes int `json:"timeoutMinutes"`
+
+	// Reported by the connection prober; Size above is the on-disk size of
+	// the database as the engine reports it
+	SizeBytes     int64      `json:"sizeBytes"`
+	ServerVersion string     `json:"serverVersion,omitempty"`
+	StatusError   string     `json:"statusError,omitempty"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt"`
+
+	// Password, ConnectionURI and SSHPrivateKey are sealed at rest and never
+	// sent back; the Has flags tell the browser whether they are set
+	HasPassword      bool `gorm:"-" json:"hasPassword"`
+	HasSSHPrivateKey bool `gorm:"-" json:"hasSshPrivateKey"`
 }
 
 type BackupSchedule struct {
-	ID            string     `gorm:"primaryKey" json:"id"`
-	DatabaseID    string     `gorm:"not null;index" json:"databaseId"`
-	DatabaseName  string     `gorm:"not null" json:"databaseName"`
-	CronExpression string    `gorm:"not null" json:"cronExpression"`
-	Enabled       bool       `gorm:"default:true" json:"enabled"`
-	NextRun       *time.Time `json:"nextRun"`
-	LastRun       *time.Time `json:"lastRun"`
-	CreatedAt     time.Time  `json:"createdAt"`
-	UpdatedAt     time.Time  `json:"updatedAt"`
+	ID             string     `gorm:"primaryKey" json:"id"`
+	DatabaseID     string     `gorm:"not null;index" json:"databaseId"`
+	DatabaseName   string     `gorm:"not null" json:"databaseName"`
+	CronExpression string     `gorm:"not null" json:"cronExpression"`
+	Enabled        bool       `gorm:"default:true" json:"enabled"`
+	NextRun        *time.Time `json:"nextRun"`
+	LastRun        *time.Time `json:"lastRun"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+
+	// IANA time zone CronExpression is read in; empty means the server's
+	Timezone string `json:"timezone,omitempty"`
+
+	// backup, or restore_test to restore the latest backup of the database
+	// into a scratch database on the sandbox server and run SanityChecks
+	Type              string        `gorm:"default:backup" json:"type"`
+	SandboxDatabaseID string        `json:"sandboxDatabaseId,omitempty"`
+	SanityChecks      []SanityCheck `gorm:"serializer:json" json:"sanityChecks,omitempty"`
+
+	// What to do with runs missed while SafeBase was down: skip, run_once
+	// (the latest, the default) or run_all
+	MisfirePolicy string `gorm:"default:run_once" json:"misfirePolicy"`
+
+	// Override the database settings when set
+	Compression      string `json:"compression,omitempty"`
+	CompressionLevel int    `json:"compressionLevel,omitempty"`
+	StorageTargetID  string `json:"storageTargetId,omitempty"`
+	TimeoutMinutes   int    `json:"timeoutMinutes,omitempty"`
+
+	// Storage targets each backup is copied to once written, and the number
+	// of copies (primary included) required; 0 means every destination
+	ReplicaTargetIDs []string `gorm:"serializer:json" json:"replicaTargetIds"`
+	MinCopies        int      `json:"minCopies"`
+
+	// Failed runs with a transient error are retried up to RetryMaxAttempts
+	// times, after RetryDelaySeconds multiplied by RetryBackoff at each
+	// retry, spread by up to RetryJitter (a fraction of the delay)
+	RetryMaxAttempts  int     `json:"retryMaxAttempts"`
+	RetryDelaySeconds int     `json:"retryDelaySeconds"`
+	RetryBackoff      float64 `json:"retryBackoff"`
+	RetryJitter       float64 `json:"retryJitter"`
+
+	// Retention (grandfather-father-son): keep the last KeepLast backups and
+	// the newest backup of each day, week and month over the last KeepDaily
+	// days, KeepWeekly weeks and KeepMonthly months, within MaxTotalSizeBytes.
+	// Without any rule nothing is pruned.
+	KeepLast          int   `json:"keepLast"`
+	KeepDaily         int   `json:"keepDaily"`
+	KeepWeekly        int   `json:"keepWeekly"`
+	KeepMonthly       int   `json:"keepMonthly"`
+	MaxTotalSizeBytes int64 `json:"maxTotalSizeBytes"`
 }
 
 type Backup struct {
-	ID           string    `gorm:"primaryKey" json:"id"`
-	DatabaseID   string    `gorm:"not null;index" json:"databaseId"`
-	DatabaseName string    `gorm:"not null" json:"databaseName"`
-	Version      string    `json:"version"`
-	Size         string    `json:"size"`
-	Status       string    `gorm:"not null" json:"status"`
-	FilePath     string    `json:"filePath"`
-	Type         string    `gorm:"not null" json:"type"`
-	Duration     int       `json:"duration"`
-	Error        string    `json:"error,omitempty"`
-	CreatedAt    time.Time `json:"createdAt"`
+	ID               string    `gorm:"primaryKey" json:"id"`
+	DatabaseID       string    `gorm:"not null;index" json:"databaseId"`
+	DatabaseName     string    `gorm:"not null" json:"databaseName"`
+	ScheduleID       string    `gorm:"index" json:"scheduleId,omitempty"` // empty for manual backups
+	Version          string    `json:"version"`
+	Size             string    `json:"size"`
+	SizeBytes        int64     `json:"sizeBytes"`
+	Status           string    `gorm:"not null" json:"status"`
+	FilePath         string    `json:"filePath"`
+	Type             string    `gorm:"not null" json:"type"`
+	Duration         int       `json:"duration"`
+	Error            string    `json:"error,omitempty"`
+	StorageTargetID  string    `json:"storageTargetId,omitempty"`
+	Compression      string    `json:"compression"`
+	CompressionRatio float64   `json:"compressionRatio"`
+	CreatedAt        time.Time `json:"createdAt"`
+
+	// SHA-256 of the stored artifact, computed while it is written and
+	// checked again by the verification job
+	Checksum   string     `json:"checksum,omitempty"`
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+
+	// Set when Compression was left to SafeBase alone: the dump tools did
+	// not compress their output, as mongodump did for every earlier backup
+	RawDump bool `json:"rawDump,omitempty"`
+
+	// Envelope encryption: the per-backup data key, wrapped by the master
+	// key identified by EncryptionKeyID
+	EncryptionKeyID  string `json:"encryptionKeyId,omitempty"`
+	EncryptedDataKey string `json:"-"`
+
+	Replicas       []BackupReplica `gorm:"foreignKey:BackupID" json:"replicas,omitempty"`
+	RequiredCopies int             `json:"requiredCopies,omitempty"`
+
+	// A retry of a failed scheduled run links to the first backup of that run
+	RetryOf string `gorm:"index" json:"retryOf,omitempty"`
+	Retry   int    `json:"retry,omitempty"`
+
+	// Seconds spent queued, waiting for a worker or a concurrency limit,
+	// before Duration started
+	WaitDuration int `json:"waitDuration"`
+}
+
+// BackupReplica is a copy of a backup artifact on an additional storage target.
+type BackupReplica struct {
+	ID              string    `gorm:"primaryKey" json:"id"`
+	BackupID        string    `gorm:"not null;index" json:"backupId"`
+	StorageTargetID string    `gorm:"not null" json:"storageTargetId"`
+	Status          string    `gorm:"not null" json:"status"` // success, failed
+	FilePath        string    `json:"filePath"`
+	Error           string    `json:"error,omitempty"`
+	Attempts        int       `json:"attempts"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+type StorageTarget struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Type      string    `gorm:"not null" json:"type"` // local, s3, sftp
+	Path      string    `json:"path"`                 // local directory, s3 key prefix or sftp directory
+	Endpoint  string    `json:"endpoint,omitempty"`   // s3 host[:port] or sftp host[:port]
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// S3
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	UseSSL    bool   `json:"useSSL"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `gorm:"serializer:sealed" json:"secretKey,omitempty"`
+
+	// SFTP
+	Username   string `json:"username,omitempty"`
+	Password   string `gorm:"serializer:sealed" json:"password,omitempty"`
+	PrivateKey string `gorm:"serializer:sealed" json:"privateKey,omitempty"`
+	HostKey    string `json:"hostKey,omitempty"` // authorized_keys format, pinned
+}
+
+// Job is a unit of background work, such as a backup, queued by the API or
+// the scheduler and run by the worker pool.
+type Job struct {
+	ID         string     `gorm:"primaryKey" json:"id"`
+	Type       string     `gorm:"not null;index" json:"type"` // backup, schedule, restore
+	DatabaseID string     `gorm:"index" json:"databaseId,omitempty"`
+	ScheduleID string     `gorm:"index" json:"scheduleId,omitempty"`
+	BackupID   string     `json:"backupId,omitempty"`           // the backup a restore replays
+	Status     string     `gorm:"not null;index" json:"status"` // queued, running, success, failed, cancelled
+	ResultID   string     `json:"resultId,omitempty"`           // the backup, drill or restore produced
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+
+	// Retries of a failed run link to its first job and wait for RunAfter
+	RetryOf  string     `gorm:"index" json:"retryOf,omitempty"`
+	Retry    int        `json:"retry,omitempty"`
+	RunAfter *time.Time `gorm:"index" json:"runAfter,omitempty"`
+
+	// The database host and storage target the job uses ("local" for the
+	// server's BACKUP_DIR), counted by the concurrency limits
+	Host    string `gorm:"index" json:"host,omitempty"`
+	Storage string `gorm:"index" json:"storage,omitempty"`
+
+	// The replica running the job, which holds it until LeaseUntil and
+	// renews it while the job runs; an expired lease lets another replica
+	// take the job over
+	WorkerID   string     `json:"workerId,omitempty"`
+	LeaseUntil *time.Time `json:"leaseUntil,omitempty"`
+}
+
+// BlackoutWindow is a period during which scheduled runs of a database, or
+// of every database when DatabaseID is empty, are deferred until it ends or
+// skipped. A window is either one-off, from StartsAt to EndsAt, or weekly on
+// Days (0 is Sunday) from StartTime to EndTime ("15:04" in Timezone), ending
+// the next day when EndTime is not after StartTime.
+type BlackoutWindow struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"not null" json:"name"`
+	DatabaseID string    `gorm:"index" json:"databaseId,omitempty"`
+	Action     string    `gorm:"default:defer" json:"action"` // defer, skip
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// One-off
+	StartsAt *time.Time `json:"startsAt,omitempty"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+
+	// Weekly
+	Days      []int  `gorm:"serializer:json" json:"days,omitempty"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+}
+
+// Lease is a named lock held by one SafeBase replica until ExpiresAt, such
+// as the scheduler leadership.
+type Lease struct {
+	Name      string    `gorm:"primaryKey" json:"name"`
+	Holder    string    `gorm:"not null" json:"holder"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ScheduleRun records that a schedule fired for a given time. The unique
+// schedule and fire time pair makes each run claimable exactly once, however
+// many triggers race for it.
+type ScheduleRun struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	ScheduleID string    `gorm:"not null;uniqueIndex:idx_schedule_run" json:"scheduleId"`
+	FireTime   time.Time `gorm:"not null;uniqueIndex:idx_schedule_run" json:"fireTime"`
+	Status     string    `gorm:"not null" json:"status"` // fired, missed
+	JobID      string    `json:"jobId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SanityCheck is a query run against a restored sandbox. It must return a
+// single value; it passes when that value equals Expect, or is at least Min,
+// or, with neither set, when the query returns a row.
+type SanityCheck struct {
+	Query  string `json:"query"`
+	Expect string `json:"expect,omitempty"`
+	Min    *int64 `json:"min,omitempty"`
+}
+
+type SanityCheckResult struct {
+	SanityCheck
+	Value  string `json:"value"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RestoreDrill records a restore test of a backup into a scratch database.
+type RestoreDrill struct {
+	ID                string              `gorm:"primaryKey" json:"id"`
+	ScheduleID        string              `gorm:"index" json:"scheduleId"`
+	DatabaseID        string              `gorm:"not null;index" json:"databaseId"`
+	DatabaseName      string              `gorm:"not null" json:"databaseName"`
+	BackupID          string              `json:"backupId"`
+	SandboxDatabaseID string              `json:"sandboxDatabaseId"`
+	Status            string              `gorm:"not null" json:"status"` // passed, failed
+	RestoreDuration   int                 `json:"restoreDuration"`
+	Duration          int                 `json:"duration"`
+	Checks            []SanityCheckResult `gorm:"serializer:json" json:"checks"`
+	Error             string              `json:"error,omitempty"`
+	CreatedAt         time.Time           `json:"createdAt"`
+}
+
+type Restore struct {
+	ID                 string    `gorm:"primaryKey" json:"id"`
+	BackupID           string    `gorm:"not null;index" json:"backupId"`
+	SourceDatabaseID   string    `gorm:"not null" json:"sourceDatabaseId"`
+	TargetDatabaseID   string    `gorm:"not null;index" json:"targetDatabaseId"`
+	TargetDatabaseName string    `gorm:"not null" json:"targetDatabaseName"`
+	Status             string    `gorm:"not null" json:"status"`
+	Duration           int       `json:"duration"`
+	Error              string    `json:"error,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
 }
 
 type Alert struct {
@@ -65,4 +336,3 @@ type Alert struct {
 	Read         bool      `gorm:"default:false" json:"read"`
 	CreatedAt    time.Time `json:"timestamp"`
 }
-
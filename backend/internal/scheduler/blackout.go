@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+const (
+	// BlackoutDefer holds runs falling within a window until it ends
+	BlackoutDefer = "defer"
+	// BlackoutSkip drops runs falling within a window
+	BlackoutSkip = "skip"
+
+	RunSkipped = "skipped"
+)
+
+func ValidateBlackout(w models.BlackoutWindow) error {
+	switch w.Action {
+	case "", BlackoutDefer, BlackoutSkip:
+	default:
+		return fmt.Errorf("unknown blackout action: %s", w.Action)
+	}
+
+	oneOff := w.StartsAt != nil || w.EndsAt != nil
+	weekly := len(w.Days) > 0 || w.StartTime != "" || w.EndTime != ""
+	switch {
+	case oneOff && weekly:
+		return errors.New("a blackout window is either one-off or weekly, not both")
+	case oneOff:
+		if w.StartsAt == nil || w.EndsAt == nil || !w.EndsAt.After(*w.StartsAt) {
+			return errors.New("a one-off blackout window needs startsAt before endsAt")
+		}
+	case weekly:
+		for _, day := range w.Days {
+			if day < 0 || day > 6 {
+				return fmt.Errorf("invalid blackout day %d, expected 0 (Sunday) to 6", day)
+			}
+		}
+		if len(w.Days) == 0 {
+			return errors.New("a weekly blackout window needs at least one day")
+		}
+		if _, err := time.Parse("15:04", w.StartTime); err != nil {
+			return fmt.Errorf("invalid blackout start time %q, expected HH:MM", w.StartTime)
+		}
+		if _, err := time.Parse("15:04", w.EndTime); err != nil {
+			return fmt.Errorf("invalid blackout end time %q, expected HH:MM", w.EndTime)
+		}
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("unknown time zone %q", w.Timezone)
+		}
+	default:
+		return errors.New("a blackout window needs startsAt and endsAt, or days, startTime and endTime")
+	}
+	return nil
+}
+
+// blackoutEnd reports whether t falls within w, and if so when w ends.
+func blackoutEnd(w models.BlackoutWindow, t time.Time) (time.Time, bool) {
+	if w.StartsAt != nil && w.EndsAt != nil {
+		return *w.EndsAt, !t.Before(*w.StartsAt) && t.Before(*w.EndsAt)
+	}
+
+	loc := time.Local
+	if w.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return time.Time{}, false
+		}
+	}
+	start, err := time.Parse("15:04", w.StartTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := time.Parse("15:04", w.EndTime)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// t falls within the occurrence starting on its own day or, for a
+	// window ending the next day, on the day before
+	local := t.In(loc)
+	for back := 0; back <= 1; back++ {
+		day := local.AddDate(0, 0, -back)
+		if !hasDay(w.Days, day.Weekday()) {
+			continue
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		until := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+		if !until.After(from) {
+			until = until.AddDate(0, 0, 1)
+		}
+		if !local.Before(from) && local.Before(until) {
+			return until, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func hasDay(days []int, weekday time.Weekday) bool {
+	for _, day := range days {
+		if time.Weekday(day) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// blackout is the effect of the windows active at a given time.
+type blackout struct {
+	Name   string
+	Action string
+	// Until is when a deferred run may start, in UTC like every stored run
+	// time
+	Until time.Time
+}
+
+// activeBlackout returns the blackout applying to runs of databaseID at t,
+// or nil. A skip window wins over defer windows; a deferred run waits for
+// every defer window, including ones starting as another ends.
+func activeBlackout(databaseID string, t time.Time) (*blackout, error) {
+	var windows []models.BlackoutWindow
+	err := database.DB.Where("database_id = ? OR database_id = ?", databaseID, "").Find(&windows).Error
+	if err != nil || len(windows) == 0 {
+		return nil, err
+	}
+
+	var active *blackout
+	for at := t; ; {
+		extended := false
+		for _, w := range windows {
+			end, ok := blackoutEnd(w, at)
+			if !ok {
+				continue
+			}
+			if w.Action == BlackoutSkip && at.Equal(t) {
+				return &blackout{Name: w.Name, Action: BlackoutSkip, Until: end.UTC()}, nil
+			}
+			if w.Action == BlackoutSkip {
+				continue
+			}
+			if active == nil {
+				active = &blackout{Name: w.Name, Action: BlackoutDefer}
+			}
+			if end.After(active.Until) {
+				active.Until = end.UTC()
+				extended = true
+			}
+		}
+		// Windows chained back to back are followed for at most a year
+		if !extended || active.Until.Sub(t) > 366*24*time.Hour {
+			return active, nil
+		}
+		at = active.Until
+	}
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/jobs"
+	"safebase-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBlackoutEnd(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	weekdays := models.BlackoutWindow{Days: []int{1, 2, 3, 4, 5}, StartTime: "09:00", EndTime: "18:00", Timezone: "Europe/Paris"}
+	overnight := models.BlackoutWindow{Days: []int{5}, StartTime: "22:00", EndTime: "06:00", Timezone: "Europe/Paris"}
+	starts := time.Date(2025, 11, 28, 0, 0, 0, 0, paris)
+	ends := starts.AddDate(0, 0, 1)
+	blackFriday := models.BlackoutWindow{StartsAt: &starts, EndsAt: &ends}
+
+	for _, c := range []struct {
+		name   string
+		window models.BlackoutWindow
+		at     time.Time
+		active bool
+		end    time.Time
+	}{
+		// Friday 14 March 2025
+		{"weekday office hours", weekdays, time.Date(2025, 3, 14, 10, 0, 0, 0, paris), true, time.Date(2025, 3, 14, 18, 0, 0, 0, paris)},
+		{"same instant in UTC", weekdays, time.Date(2025, 3, 14, 9, 0, 0, 0, time.UTC), true, time.Date(2025, 3, 14, 18, 0, 0, 0, paris)},
+		{"weekday evening", weekdays, time.Date(2025, 3, 14, 18, 0, 0, 0, paris), false, time.Time{}},
+		{"weekend", weekdays, time.Date(2025, 3, 15, 10, 0, 0, 0, paris), false, time.Time{}},
+		{"overnight start", overnight, time.Date(2025, 3, 14, 23, 0, 0, 0, paris), true, time.Date(2025, 3, 15, 6, 0, 0, 0, paris)},
+		{"overnight after midnight", overnight, time.Date(2025, 3, 15, 2, 0, 0, 0, paris), true, time.Date(2025, 3, 15, 6, 0, 0, 0, paris)},
+		{"overnight other day", overnight, time.Date(2025, 3, 16, 2, 0, 0, 0, paris), false, time.Time{}},
+		{"one-off", blackFriday, starts.Add(12 * time.Hour), true, ends},
+		{"after one-off", blackFriday, ends, false, time.Time{}},
+	} {
+		end, active := blackoutEnd(c.window, c.at)
+		if active != c.active || (active && !end.Equal(c.end)) {
+			t.Errorf("%s: expected %v until %s, got %v until %s", c.name, c.active, c.end, active, end)
+		}
+	}
+}
+
+func TestTickHonoursBlackouts(t *testing.T) {
+	setupDB(t)
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.Local)
+	schedule := dueSchedule(t, "0 * * * *", now)
+
+	window := models.BlackoutWindow{ID: "office", Name: "office hours", DatabaseID: "db", Action: BlackoutDefer,
+		Days: []int{5}, StartTime: "09:00", EndTime: "18:00"}
+	if err := database.DB.Create(&window).Error; err != nil {
+		t.Fatal(err)
+	}
+	s := NewScheduler(t.TempDir())
+
+	// Deferred runs wait for the end of the window, merged into one job
+	s.tick(now)
+	database.DB.Model(&schedule).Update("next_run", now.Add(time.Hour))
+	s.tick(now.Add(time.Hour))
+
+	var queued []models.Job
+	database.DB.Where("schedule_id = ? AND status = ?", schedule.ID, jobs.StatusQueued).Find(&queued)
+	if len(queued) != 1 || queued[0].RunAfter == nil || !queued[0].RunAfter.Equal(time.Date(2025, 3, 14, 18, 0, 0, 0, time.Local)) {
+		t.Fatalf("Expected one job deferred to 18:00, got %+v", queued)
+	}
+	if runs, _ := countRuns(t); runs != 2 {
+		t.Errorf("Expected both runs to be claimed, got %d", runs)
+	}
+
+	// A skip window drops the run
+	database.DB.Model(&window).Update("action", BlackoutSkip)
+	database.DB.Model(&schedule).Update("next_run", now.Add(2*time.Hour))
+	s.tick(now.Add(2 * time.Hour))
+
+	var skipped int64
+	database.DB.Model(&models.ScheduleRun{}).Where("status = ?", RunSkipped).Count(&skipped)
+	if _, jobCount := countRuns(t); skipped != 1 || jobCount != 1 {
+		t.Errorf("Expected the run to be skipped without a job, got %d skipped and %d jobs", skipped, jobCount)
+	}
+}
+
+func TestScheduleTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	sched, err := scheduleOf(models.BackupSchedule{CronExpression: "0 2 * * *", Timezone: "America/New_York"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 02:00 in New York is 06:00 UTC during daylight saving time
+	next := nextRun(sched, time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	if !next.Equal(time.Date(2025, 6, 2, 6, 0, 0, 0, time.UTC)) || next.Location() != time.UTC {
+		t.Errorf("Expected 06:00 UTC, got %s", next)
+	}
+}
+
+func TestNextRunInUTCAcrossDaylightSaving(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database unavailable")
+	}
+	local := time.Local
+	time.Local = paris
+	defer func() { time.Local = local }()
+
+	// Without a timezone, 02:00 is in the server's local time, stored in UTC
+	// on both sides of the switch to summer time
+	sched, err := scheduleOf(models.BackupSchedule{CronExpression: "0 2 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	winter := nextRun(sched, time.Date(2025, 3, 28, 12, 0, 0, 0, time.UTC))
+	summer := nextRun(sched, time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC))
+	if !winter.Equal(time.Date(2025, 3, 29, 1, 0, 0, 0, time.UTC)) || winter.Location() != time.UTC {
+		t.Errorf("Expected 01:00 UTC in winter, got %s", winter)
+	}
+	if !summer.Equal(time.Date(2025, 4, 2, 0, 0, 0, 0, time.UTC)) || summer.Location() != time.UTC {
+		t.Errorf("Expected 00:00 UTC in summer, got %s", summer)
+	}
+}
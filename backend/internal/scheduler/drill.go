@@ -0,0 +1,48 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+// RunDrill restores the latest successful backup of the schedule's database
+// into a sandbox, records the drill and alerts when it fails.
+func (s *Scheduler) RunDrill(ctx context.Context, schedule models.BackupSchedule) (models.RestoreDrill, error) {
+	var server models.Database
+	if err := database.DB.First(&server, "id = ?", schedule.SandboxDatabaseID).Error; err != nil {
+		return models.RestoreDrill{}, errors.New("sandbox server not found")
+	}
+
+	var latest models.Backup
+	err := database.DB.Preload("Replicas").Where("database_id = ? AND status = ?", schedule.DatabaseID, "success").
+		Order("created_at DESC").First(&latest).Error
+	if err != nil {
+		return models.RestoreDrill{}, fmt.Errorf("no successful backup of %s to test", schedule.DatabaseName)
+	}
+
+	drill, err := s.BackupExec.ExecuteDrill(ctx, latest, server, schedule.SanityChecks)
+	drill.ScheduleID = schedule.ID
+	database.DB.Create(&drill)
+
+	if drill.Status != "passed" {
+		database.CreateAlert("error", "Restore drill failed",
+			fmt.Sprintf("Backup of %s could not be restored and checked: %s", latest.CreatedAt.Format("2006-01-02 15:04"), drill.Error),
+			schedule.DatabaseName)
+	}
+
+	database.UpdateScheduleLastRun(schedule.ID, time.Now())
+	return drill, err
+}
+
+func (s *Scheduler) executeDrill(ctx context.Context, schedule models.BackupSchedule) (models.RestoreDrill, error) {
+	drill, err := s.RunDrill(ctx, schedule)
+	// Setup errors happen before a drill is recorded
+	if err != nil && drill.ID == "" {
+		database.CreateAlert("error", "Restore drill failed", err.Error(), schedule.DatabaseName)
+	}
+	return drill, err
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+const defaultHealthInterval = 5 * time.Minute
+
+// startHealthProbe periodically connects to every database to keep its
+// status, version and size current.
+func (s *Scheduler) startHealthProbe() {
+	go func() {
+		ticker := time.NewTicker(s.HealthInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if s.IsLeader() {
+					s.probeAll()
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) probeAll() {
+	var databases []models.Database
+	if err := database.DB.Find(&databases).Error; err != nil {
+		log.Printf("Failed to load databases to probe: %v", err)
+		return
+	}
+	for _, db := range databases {
+		s.ProbeDatabase(db)
+	}
+}
+
+// ProbeDatabase connects to db and records its status. An alert is raised
+// when a database that was connected no longer is.
+func (s *Scheduler) ProbeDatabase(db models.Database) backup.ConnectionReport {
+	report := s.BackupExec.Probe(db)
+	now := time.Now()
+
+	updates := map[string]interface{}{
+		"status":          report.Status(),
+		"status_error":    report.Error,
+		"last_checked_at": &now,
+	}
+	if report.Version != "" {
+		updates["server_version"] = report.Version
+	}
+	if report.Size != "" {
+		updates["size"] = report.Size
+		updates["size_bytes"] = report.SizeBytes
+	}
+	if err := database.DB.Model(&models.Database{}).Where("id = ?", db.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record the status of database %s: %v", db.Name, err)
+	}
+
+	if db.Status == "connected" && report.Status() != "connected" {
+		database.CreateAlert("error", "Database unreachable",
+			fmt.Sprintf("Database %s is %s: %s", db.Name, report.Status(), report.Error),
+			db.Name)
+	}
+	return report
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/jobs"
+	"safebase-backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	JobTypeBackup   = "backup"
+	JobTypeSchedule = "schedule"
+	JobTypeRestore  = "restore"
+)
+
+// EnqueueBackup queues a manual backup of db.
+func (s *Scheduler) EnqueueBackup(db models.Database) (models.Job, error) {
+	opts := backup.OptionsFor(db, nil)
+	return s.Jobs.Enqueue(models.Job{
+		Type:       JobTypeBackup,
+		DatabaseID: db.ID,
+		Host:       backup.HostKey(db),
+		Storage:    backup.StorageKey(opts.StorageTargetID),
+	})
+}
+
+// EnqueueRestore queues the restore of b into target.
+func (s *Scheduler) EnqueueRestore(b models.Backup, target models.Database) (models.Job, error) {
+	return s.Jobs.Enqueue(models.Job{
+		Type:       JobTypeRestore,
+		DatabaseID: target.ID,
+		BackupID:   b.ID,
+		Host:       backup.HostKey(target),
+		Storage:    backup.StorageKey(b.StorageTargetID),
+	})
+}
+
+// EnqueueSchedule queues a run of schedule: a backup, or a restore drill.
+func (s *Scheduler) EnqueueSchedule(schedule models.BackupSchedule) (models.Job, error) {
+	return s.Jobs.Enqueue(scheduleJob(database.DB, schedule))
+}
+
+// scheduleJob describes a run of schedule, with the database host and the
+// storage target it uses for the concurrency limits. A restore drill loads
+// the sandbox server rather than the source database.
+func scheduleJob(tx *gorm.DB, schedule models.BackupSchedule) models.Job {
+	job := models.Job{Type: JobTypeSchedule, DatabaseID: schedule.DatabaseID, ScheduleID: schedule.ID}
+
+	var db models.Database
+	if err := tx.First(&db, "id = ?", schedule.DatabaseID).Error; err != nil {
+		return job
+	}
+	job.Host = backup.HostKey(db)
+	job.Storage = backup.StorageKey(backup.OptionsFor(db, &schedule).StorageTargetID)
+
+	if schedule.Type == backup.ScheduleTypeRestoreTest {
+		var server models.Database
+		if err := tx.First(&server, "id = ?", schedule.SandboxDatabaseID).Error; err == nil {
+			job.Host = backup.HostKey(server)
+		}
+	}
+	return job
+}
+
+// waitOf returns how long job was queued before a worker started it,
+// counted from when it was due.
+func waitOf(job *models.Job) time.Duration {
+	if job.StartedAt == nil {
+		return 0
+	}
+	due := job.CreatedAt
+	if job.RunAfter != nil && job.RunAfter.After(due) {
+		due = *job.RunAfter
+	}
+	if wait := job.StartedAt.Sub(due); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// progressOf forwards backup progress to the job's event subscribers.
+func progressOf(publish jobs.Publisher) backup.Reporter {
+	return backup.ReporterFunc(func(event backup.ProgressEvent) {
+		publish(event.Type, event)
+	})
+}
+
+func (s *Scheduler) runBackupJob(ctx context.Context, job *models.Job, publish jobs.Publisher) error {
+	var db models.Database
+	if err := database.DB.First(&db, "id = ?", job.DatabaseID).Error; err != nil {
+		return errors.New("database not found")
+	}
+
+	opts := backup.OptionsFor(db, nil)
+	opts.Progress = progressOf(publish)
+	opts.Wait = waitOf(job)
+	b, err := s.BackupExec.ExecuteBackup(ctx, db, opts)
+	b.Type = "manual"
+	database.DB.Create(&b)
+	job.ResultID = b.ID
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	database.DB.Model(&models.Database{}).Where("id = ?", db.ID).Updates(map[string]interface{}{
+		"last_backup":  &now,
+		"backup_count": gorm.Expr("backup_count + 1"),
+	})
+	return nil
+}
+
+func (s *Scheduler) runRestoreJob(ctx context.Context, job *models.Job, publish jobs.Publisher) error {
+	var b models.Backup
+	if err := database.DB.Preload("Replicas").First(&b, "id = ?", job.BackupID).Error; err != nil {
+		return errors.New("backup not found")
+	}
+	var target models.Database
+	if err := database.DB.First(&target, "id = ?", job.DatabaseID).Error; err != nil {
+		return errors.New("target database not found")
+	}
+
+	restore, err := s.BackupExec.ExecuteRestore(ctx, b, target)
+	database.DB.Create(&restore)
+	job.ResultID = restore.ID
+	if err != nil {
+		database.CreateAlert("error", "Restore failed", err.Error(), target.Name)
+		return err
+	}
+
+	database.CreateAlert("success", "Restore completed",
+		fmt.Sprintf("Backup from %s restored", b.CreatedAt.Format("2006-01-02 15:04")), target.Name)
+	return nil
+}
+
+func (s *Scheduler) runScheduleJob(ctx context.Context, job *models.Job, publish jobs.Publisher) error {
+	// Reload so the run uses the settings current when it starts
+	var schedule models.BackupSchedule
+	if err := database.DB.First(&schedule, "id = ?", job.ScheduleID).Error; err != nil {
+		return errors.New("schedule not found")
+	}
+	if job.Retry > 0 && !schedule.Enabled {
+		return errors.New("schedule disabled")
+	}
+
+	if schedule.Type == backup.ScheduleTypeRestoreTest {
+		drill, err := s.executeDrill(ctx, schedule)
+		job.ResultID = drill.ID
+		if err == nil && drill.Status != "passed" {
+			err = errors.New(drill.Error)
+		}
+		return err
+	}
+
+	var db models.Database
+	if err := database.DB.First(&db, "id = ?", schedule.DatabaseID).Error; err != nil {
+		return errors.New("database not found")
+	}
+
+	opts := backup.OptionsFor(db, &schedule)
+	opts.Progress = progressOf(publish)
+	opts.Wait = waitOf(job)
+	if job.RetryOf != "" {
+		if first, err := jobs.Get(job.RetryOf); err == nil {
+			opts.RetryOf = first.ResultID
+		}
+		opts.Retry = job.Retry
+	}
+
+	b, err := s.executeBackup(ctx, schedule, db, opts)
+	job.ResultID = b.ID
+	if err != nil {
+		s.retryRun(schedule, *job, err)
+	}
+	return err
+}
+
+// retryRun queues the next attempt of a failed scheduled backup when its
+// error is transient and the schedule's retry policy has attempts left.
+func (s *Scheduler) retryRun(schedule models.BackupSchedule, job models.Job, runErr error) {
+	if !backup.IsTransient(runErr) {
+		return
+	}
+	delay, ok := backup.RetryFor(schedule).Delay(job.Retry+1, rand.Float64())
+	if !ok {
+		return
+	}
+
+	first := job.ID
+	if job.RetryOf != "" {
+		first = job.RetryOf
+	}
+	runAfter := time.Now().UTC().Add(delay)
+	retry := scheduleJob(database.DB, schedule)
+	retry.RetryOf = first
+	retry.Retry = job.Retry + 1
+	retry.RunAfter = &runAfter
+	_, err := s.Jobs.Enqueue(retry)
+	if err != nil {
+		log.Printf("Failed to queue retry of schedule %s: %v", schedule.ID, err)
+		return
+	}
+	log.Printf("Backup of %s failed, retry %d in %s: %v", schedule.DatabaseName, job.Retry+1, delay.Round(time.Second), runErr)
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"testing"
+)
+
+func TestRestoreRunsAsJob(t *testing.T) {
+	setupDB(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shop.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE orders (id INTEGER); INSERT INTO orders VALUES (1), (2)"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewScheduler(filepath.Join(dir, "backups"))
+	s.BackupExec.SQLiteRoot = dir
+	db := models.Database{ID: "db", Name: "shop", Type: "sqlite", Host: path}
+	database.DB.Create(&db)
+	b, err := s.BackupExec.ExecuteBackup(context.Background(), db, backup.OptionsFor(db, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Create(&b)
+	conn.Exec("DELETE FROM orders")
+
+	job, err := s.EnqueueRestore(b, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != JobTypeRestore || job.Status != "queued" || job.BackupID != b.ID {
+		t.Fatalf("Expected a queued restore job, got %+v", job)
+	}
+	if err := s.runRestoreJob(context.Background(), &job, func(string, interface{}) {}); err != nil {
+		t.Fatalf("Restore job failed: %v", err)
+	}
+
+	var restore models.Restore
+	if err := database.DB.First(&restore, "id = ?", job.ResultID).Error; err != nil || restore.Status != "success" {
+		t.Errorf("Expected the job to record a successful restore, got %+v, %v", restore, err)
+	}
+	var count int
+	conn.QueryRow("SELECT COUNT(*) FROM orders").Scan(&count)
+	if count != 2 {
+		t.Errorf("Expected 2 orders after the restore, got %d", count)
+	}
+}
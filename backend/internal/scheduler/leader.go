@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"log"
+	"safebase-backend/internal/database"
+	"time"
+)
+
+const (
+	// leaderLease is the lease held by the replica that fires schedules and
+	// runs the periodic maintenance
+	leaderLease    = "scheduler"
+	leaderLeaseTTL = 30 * time.Second
+)
+
+// IsLeader reports whether this replica currently fires schedules. Replicas
+// that are not the leader still run queued jobs.
+func (s *Scheduler) IsLeader() bool {
+	return s.leader.Load()
+}
+
+// startLeaderElection keeps trying to take the leader lease, and renews it
+// while this replica holds it. If the leader dies its lease expires and
+// another replica takes over within leaderLeaseTTL.
+func (s *Scheduler) startLeaderElection() {
+	s.campaign()
+	go func() {
+		ticker := time.NewTicker(leaderLeaseTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.campaign()
+			case <-s.stop:
+				if s.leader.Load() {
+					database.ReleaseLease(leaderLease, s.Jobs.ID)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) campaign() {
+	acquired, err := database.AcquireLease(leaderLease, s.Jobs.ID, leaderLeaseTTL)
+	if err != nil {
+		// Without a renewal the lease may lapse, so stop acting as leader
+		log.Printf("Failed to renew scheduler lease: %v", err)
+		acquired = false
+	}
+
+	if was := s.leader.Swap(acquired); was != acquired {
+		if acquired {
+			log.Printf("Replica %s is now the scheduler leader", s.Jobs.ID)
+		} else {
+			log.Printf("Replica %s is no longer the scheduler leader", s.Jobs.ID)
+		}
+	}
+}
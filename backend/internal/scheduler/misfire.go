@@ -0,0 +1,128 @@
+package scheduler
+
+import (
+	"fmt"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Misfire policies decide what happens to the runs of a schedule missed
+// while no replica was firing it.
+const (
+	// MisfireSkip records every missed run and waits for the next one
+	MisfireSkip = "skip"
+	// MisfireRunOnce runs the latest missed run now and records the others
+	MisfireRunOnce = "run_once"
+	// MisfireRunAll runs every missed run, up to maxCatchUpRuns
+	MisfireRunAll = "run_all"
+
+	RunFired  = "fired"
+	RunMissed = "missed"
+)
+
+const (
+	// misfireGrace is how late a run may start and still count as on time
+	misfireGrace = time.Minute
+	// maxCatchUpRuns bounds the runs queued by MisfireRunAll; older missed
+	// runs are recorded as missed
+	maxCatchUpRuns = 24
+	// maxMissedRuns bounds the missed runs recorded after a long downtime,
+	// e.g. for a schedule firing every minute
+	maxMissedRuns = 100
+)
+
+func ValidMisfirePolicy(policy string) bool {
+	switch policy {
+	case "", MisfireSkip, MisfireRunOnce, MisfireRunAll:
+		return true
+	}
+	return false
+}
+
+// missedRuns lists the fire times of schedule from its NextRun up to now,
+// keeping the latest maxMissedRuns.
+func missedRuns(schedule models.BackupSchedule, now time.Time) ([]time.Time, error) {
+	sched, err := scheduleOf(schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	for t := schedule.NextRun.UTC(); !t.After(now); t = nextRun(sched, t) {
+		times = append(times, t)
+		if len(times) > maxMissedRuns {
+			times = times[1:]
+		}
+	}
+	return times, nil
+}
+
+// catchUp applies the misfire policy of schedule to the runs it missed up to
+// now, then raises an alert listing the runs skipped.
+func (s *Scheduler) catchUp(schedule models.BackupSchedule, now time.Time) error {
+	missed, err := missedRuns(schedule, now)
+	if err != nil || len(missed) == 0 {
+		return err
+	}
+
+	var run []time.Time
+	switch schedule.MisfirePolicy {
+	case MisfireSkip:
+	case MisfireRunAll:
+		split := len(missed) - maxCatchUpRuns
+		if split < 0 {
+			split = 0
+		}
+		missed, run = missed[:split], missed[split:]
+	default:
+		missed, run = missed[:len(missed)-1], missed[len(missed)-1:]
+	}
+
+	claimed, err := s.claimRuns(schedule, run, missed, now)
+	if err != nil || !claimed || len(missed) == 0 {
+		return err
+	}
+
+	message := fmt.Sprintf("%d scheduled run(s) missed between %s and %s while SafeBase was down",
+		len(missed), missed[0].Format("2006-01-02 15:04"), missed[len(missed)-1].Format("2006-01-02 15:04"))
+	if len(run) > 0 {
+		message += fmt.Sprintf(", %d run(s) started to catch up", len(run))
+	}
+	database.CreateAlert("warning", "Scheduled runs missed", message, schedule.DatabaseName)
+	return nil
+}
+
+// recordMissed claims the run of schedule at fireTime as missed and, for a
+// backup schedule, logs it as a missed backup.
+func recordMissed(tx *gorm.DB, schedule models.BackupSchedule, fireTime, now time.Time) error {
+	run := models.ScheduleRun{
+		ID:         uuid.New().String(),
+		ScheduleID: schedule.ID,
+		FireTime:   runKey(fireTime),
+		Status:     RunMissed,
+		CreatedAt:  now,
+	}
+	if err := tx.Create(&run).Error; err != nil {
+		return err
+	}
+
+	if schedule.Type == backup.ScheduleTypeRestoreTest {
+		return nil
+	}
+	missed := models.Backup{
+		ID:           uuid.New().String(),
+		DatabaseID:   schedule.DatabaseID,
+		DatabaseName: schedule.DatabaseName,
+		ScheduleID:   schedule.ID,
+		Status:       "missed",
+		Type:         "scheduled",
+		Error:        fmt.Sprintf("scheduled run at %s missed while SafeBase was down", fireTime.Format("2006-01-02 15:04")),
+		CreatedAt:    fireTime,
+	}
+	return tx.Create(&missed).Error
+}
@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+const (
+	replicaRetryInterval = 5 * time.Minute
+	maxReplicaAttempts   = 5
+)
+
+// CheckReplication raises an alert when a backup exists in fewer places than
+// its schedule requires.
+func CheckReplication(b models.Backup) {
+	copies := backup.CopyCount(b)
+	if b.Status != "success" || copies >= b.RequiredCopies {
+		return
+	}
+
+	database.CreateAlert("warning", "Backup under-replicated",
+		fmt.Sprintf("Backup stored in %d of %d required locations, failed copies will be retried", copies, b.RequiredCopies),
+		b.DatabaseName)
+}
+
+// startReplicaRetry periodically retries failed replica uploads until they
+// succeed or run out of attempts.
+func (s *Scheduler) startReplicaRetry() {
+	go func() {
+		ticker := time.NewTicker(replicaRetryInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if s.IsLeader() {
+				s.retryFailedReplicas()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) retryFailedReplicas() {
+	var replicas []models.BackupReplica
+	err := database.DB.Where("status = ? AND attempts < ?", "failed", maxReplicaAttempts).Find(&replicas).Error
+	if err != nil {
+		log.Printf("Failed to load replicas to retry: %v", err)
+		return
+	}
+
+	for _, replica := range replicas {
+		var b models.Backup
+		if err := database.DB.Preload("Replicas").First(&b, "id = ?", replica.BackupID).Error; err != nil {
+			continue
+		}
+
+		err := s.BackupExec.CopyReplica(context.Background(), b, &replica)
+		database.DB.Save(&replica)
+
+		if err == nil {
+			log.Printf("Replica of backup %s uploaded to %s", b.ID, replica.StorageTargetID)
+			continue
+		}
+
+		if replica.Attempts >= maxReplicaAttempts {
+			database.CreateAlert("error", "Backup under-replicated",
+				fmt.Sprintf("Giving up on copy to storage target %s after %d attempts: %s", replica.StorageTargetID, replica.Attempts, replica.Error),
+				b.DatabaseName)
+		}
+	}
+}
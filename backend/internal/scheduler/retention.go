@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Prune applies the retention policy of schedule to the backups it made,
+// deleting the stored files and then the rows. Manual backups and those of
+// the database's other schedules are left alone. With dryRun it only
+// returns what would be deleted.
+func (s *Scheduler) Prune(schedule models.BackupSchedule, dryRun bool) ([]models.Backup, error) {
+	policy := backup.RetentionFor(schedule)
+	if !policy.Enabled() {
+		return []models.Backup{}, nil
+	}
+
+	var backups []models.Backup
+	err := database.DB.Preload("Replicas").Where("schedule_id = ?", schedule.ID).Find(&backups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	prunable := backup.SelectPrunable(backups, policy, time.Now())
+	if dryRun || len(prunable) == 0 {
+		return prunable, nil
+	}
+
+	pruned := []models.Backup{}
+	var failures int
+	var lastErr error
+	for _, b := range prunable {
+		if err := s.BackupExec.DeleteArtifacts(b); err != nil {
+			// Keep the row so the next run retries the deletion
+			log.Printf("Failed to prune backup %s: %v", b.ID, err)
+			failures++
+			lastErr = err
+			continue
+		}
+
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("backup_id = ?", b.ID).Delete(&models.BackupReplica{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Backup{}, "id = ?", b.ID).Error; err != nil {
+				return err
+			}
+			if b.Status != "success" {
+				return nil
+			}
+			return tx.Model(&models.Database{}).Where("id = ? AND backup_count > 0", b.DatabaseID).
+				UpdateColumn("backup_count", gorm.Expr("backup_count - 1")).Error
+		})
+		if err != nil {
+			log.Printf("Failed to prune backup %s: %v", b.ID, err)
+			failures++
+			lastErr = err
+			continue
+		}
+		pruned = append(pruned, b)
+	}
+
+	if len(pruned) > 0 {
+		log.Printf("Pruned %d backups of %s", len(pruned), schedule.DatabaseName)
+	}
+	if failures > 0 {
+		database.CreateAlert("warning", "Backup pruning incomplete",
+			fmt.Sprintf("%d backups could not be deleted, they will be retried on the next run: %v", failures, lastErr),
+			schedule.DatabaseName)
+	}
+	return pruned, nil
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPruneKeepsOtherSchedulesAndManualBackups(t *testing.T) {
+	setupDB(t)
+	schedule := dueSchedule(t, "0 2 * * *", time.Now().Add(time.Hour))
+	database.DB.Model(&schedule).Update("keep_last", 1)
+	schedule.KeepLast = 1
+
+	now := time.Now()
+	for i, scheduleID := range []string{"nightly", "nightly", "nightly", "hourly", "hourly", ""} {
+		b := models.Backup{
+			ID:           string(rune('a' + i)),
+			DatabaseID:   "db",
+			DatabaseName: "shop",
+			ScheduleID:   scheduleID,
+			Status:       "success",
+			Type:         "scheduled",
+			CreatedAt:    now.Add(-time.Duration(i) * time.Hour),
+		}
+		if err := database.DB.Create(&b).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := NewScheduler(t.TempDir()).Prune(schedule, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("Expected the two older nightly backups to be pruned, got %d", len(pruned))
+	}
+
+	var remaining []models.Backup
+	database.DB.Order("id").Find(&remaining)
+	var ids string
+	for _, b := range remaining {
+		ids += b.ID
+	}
+	// The newest nightly backup, both hourly ones and the manual one survive
+	if ids != "adef" {
+		t.Errorf("Expected backups a, d, e and f to remain, got %q", ids)
+	}
+}
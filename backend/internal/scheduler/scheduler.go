@@ -1,101 +1,275 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"safebase-backend/internal/backup"
 	"safebase-backend/internal/database"
+	"safebase-backend/internal/jobs"
 	"safebase-backend/internal/models"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
 )
 
+// tickInterval is how often due schedules are looked for. Cron expressions
+// may have a seconds field, so it is the finest resolution they need.
+const tickInterval = time.Second
+
+// cronParser accepts the standard 5-field expressions, an optional leading
+// seconds field and descriptors such as @daily.
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseCron parses a schedule's cron expression.
+func ParseCron(expr string) (cron.Schedule, error) {
+	return cronParser.Parse(expr)
+}
+
+// scheduleOf parses the cron expression of schedule in its timezone, or in
+// the server's local time when it has none.
+func scheduleOf(schedule models.BackupSchedule) (cron.Schedule, error) {
+	expr := schedule.CronExpression
+	if schedule.Timezone != "" {
+		expr = "CRON_TZ=" + schedule.Timezone + " " + expr
+	}
+	sched, err := ParseCron(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", schedule.CronExpression, err)
+	}
+	return sched, nil
+}
+
+// nextRun returns the first fire time of sched after t, in UTC: SQLite
+// compares stored times as strings, so they must all share one zone, and
+// the local offset changes with daylight saving time. Schedules without a
+// timezone are still evaluated in the server's local time.
+func nextRun(sched cron.Schedule, t time.Time) time.Time {
+	return sched.Next(t.In(time.Local)).UTC()
+}
+
+// Scheduler fires schedules from their persisted NextRun, the single source
+// of truth for when a schedule is due. Each run is claimed through a unique
+// ScheduleRun record before its job is queued, so a run fires exactly once
+// even if several triggers see it due. With several replicas only the
+// leader fires schedules; every replica runs queued jobs.
 type Scheduler struct {
-	cron          *cron.Cron
-	BackupExec    *backup.BackupExecutor
-	scheduleJobs  map[string]cron.EntryID
+	BackupExec *backup.BackupExecutor
+	// VerifyInterval is how often each stored backup is re-verified
+	VerifyInterval time.Duration
+	// HealthInterval is how often every database is probed
+	HealthInterval time.Duration
+	// Jobs runs backups in the background, for the API and the cron alike
+	Jobs *jobs.Queue
+
+	leader atomic.Bool
+	stop   chan struct{}
 }
 
 func NewScheduler(backupDir string) *Scheduler {
-	c := cron.New(cron.WithSeconds())
-	return &Scheduler{
-		cron:         c,
-		BackupExec:   backup.NewBackupExecutor(backupDir),
-		scheduleJobs: make(map[string]cron.EntryID),
+	backupExec := backup.NewBackupExecutor(backupDir)
+	backupExec.OpenStorage = database.OpenStorageTarget
+	s := &Scheduler{
+		BackupExec:     backupExec,
+		VerifyInterval: defaultVerifyInterval,
+		HealthInterval: defaultHealthInterval,
+		Jobs:           jobs.NewQueue(jobs.DefaultWorkers),
+		stop:           make(chan struct{}),
 	}
+	s.Jobs.Register(JobTypeBackup, s.runBackupJob)
+	s.Jobs.Register(JobTypeSchedule, s.runScheduleJob)
+	s.Jobs.Register(JobTypeRestore, s.runRestoreJob)
+	return s
 }
 
 func (s *Scheduler) Start() {
-	s.cron.Start()
+	s.Jobs.Start()
+	s.startLeaderElection()
 	s.loadAndScheduleAll()
-	s.startPeriodicCheck()
+	s.startTicker()
+	s.startReplicaRetry()
+	s.startVerification()
+	s.startHealthProbe()
 }
 
 func (s *Scheduler) Stop() {
-	s.cron.Stop()
+	close(s.stop)
+	s.Jobs.Stop()
 }
 
+// loadAndScheduleAll computes NextRun for enabled schedules that have none,
+// such as schedules created before the scheduler tracked it. A schedule that
+// ran before resumes from its LastRun, so the runs missed since are caught
+// up by its misfire policy.
 func (s *Scheduler) loadAndScheduleAll() {
 	var schedules []models.BackupSchedule
-	database.DB.Where("enabled = ?", true).Find(&schedules)
+	database.DB.Where("enabled = ? AND next_run IS NULL", true).Find(&schedules)
 
 	for _, schedule := range schedules {
-		s.AddSchedule(schedule)
+		sched, err := scheduleOf(schedule)
+		if err != nil {
+			continue
+		}
+		from := time.Now()
+		if schedule.LastRun != nil {
+			from = *schedule.LastRun
+		}
+		database.UpdateScheduleNextRun(schedule.ID, nextRun(sched, from))
 	}
 }
 
-func (s *Scheduler) startPeriodicCheck() {
+func (s *Scheduler) startTicker() {
 	go func() {
-		ticker := time.NewTicker(1 * time.Minute)
+		ticker := time.NewTicker(tickInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			schedules, err := database.GetEnabledSchedules()
-			if err != nil {
-				continue
-			}
-
-			for _, schedule := range schedules {
-				if schedule.NextRun != nil && schedule.NextRun.Before(time.Now()) {
-					s.executeBackupNow(schedule)
-					s.CalculateAndUpdateNextRun(schedule)
+		for {
+			select {
+			case now := <-ticker.C:
+				if s.IsLeader() {
+					s.tick(now)
 				}
+			case <-s.stop:
+				return
 			}
 		}
 	}()
 }
 
-func (s *Scheduler) AddSchedule(schedule models.BackupSchedule) {
-	if !schedule.Enabled {
+// tick fires every enabled schedule whose NextRun has passed. A run overdue
+// by more than misfireGrace was missed, because the server or the leader
+// was down, and is handled by the schedule's misfire policy.
+func (s *Scheduler) tick(now time.Time) {
+	var due []models.BackupSchedule
+	err := database.DB.Where("enabled = ? AND next_run IS NOT NULL AND next_run <= ?", true, now.UTC()).Find(&due).Error
+	if err != nil {
+		log.Printf("Failed to load due schedules: %v", err)
 		return
 	}
 
-	expr := s.convertCronToStandard(schedule.CronExpression)
-	if expr == "" {
-		return
+	for _, schedule := range due {
+		if now.Sub(*schedule.NextRun) > misfireGrace {
+			err = s.catchUp(schedule, now)
+		} else {
+			_, err = s.fire(schedule, *schedule.NextRun, now)
+		}
+		if err != nil {
+			log.Printf("Failed to fire schedule %s: %v", schedule.ID, err)
+		}
 	}
+}
 
-	var db models.Database
-	if err := database.DB.First(&db, "id = ?", schedule.DatabaseID).Error; err != nil {
-		return
+// fire claims the run of schedule due at fireTime, queues its job and moves
+// NextRun past now. It returns false when the run was already claimed.
+func (s *Scheduler) fire(schedule models.BackupSchedule, fireTime, now time.Time) (bool, error) {
+	return s.claimRuns(schedule, []time.Time{fireTime}, nil, now)
+}
+
+// claimRuns claims the runs of schedule at the fire times in run, queueing
+// a job for each, and those in missed, recording them as missed, then moves
+// NextRun past now, all in one transaction. It returns false when any of
+// the runs was already claimed, in which case none is. Runs falling in a
+// blackout window are skipped, or deferred until it ends.
+func (s *Scheduler) claimRuns(schedule models.BackupSchedule, run, missed []time.Time, now time.Time) (bool, error) {
+	sched, err := scheduleOf(schedule)
+	if err != nil {
+		return false, err
+	}
+	var window *blackout
+	if len(run) > 0 {
+		if window, err = activeBlackout(schedule.DatabaseID, now); err != nil {
+			return false, err
+		}
 	}
 
-	entryID, err := s.cron.AddFunc(expr, func() {
-		s.executeBackup(schedule, db)
-	})
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, fireTime := range missed {
+			if err := recordMissed(tx, schedule, fireTime, now); err != nil {
+				return err
+			}
+		}
+
+		for _, fireTime := range run {
+			record := models.ScheduleRun{
+				ID:         uuid.New().String(),
+				ScheduleID: schedule.ID,
+				FireTime:   runKey(fireTime),
+				Status:     RunFired,
+				CreatedAt:  now,
+			}
+			if window != nil && window.Action == BlackoutSkip {
+				record.Status = RunSkipped
+			} else {
+				jobID, err := s.enqueueRun(tx, schedule, window)
+				if err != nil {
+					return err
+				}
+				record.JobID = jobID
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
 
+		return tx.Model(&models.BackupSchedule{}).Where("id = ?", schedule.ID).Update("next_run", nextRun(sched, now)).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return false, nil
+	}
 	if err != nil {
-		return
+		return false, err
+	}
+
+	if window != nil && len(run) > 0 {
+		log.Printf("Schedule %s is in blackout window %q: run %s", schedule.ID, window.Name, map[string]string{
+			BlackoutSkip:  "skipped",
+			BlackoutDefer: "deferred until " + window.Until.Format(time.RFC3339),
+		}[window.Action])
 	}
+	if len(run) > 0 {
+		s.Jobs.Notify()
+	}
+	return true, nil
+}
+
+// enqueueRun queues the job of a schedule run within tx. During a defer
+// blackout the job waits for the window to end; runs deferred together are
+// merged into one job.
+func (s *Scheduler) enqueueRun(tx *gorm.DB, schedule models.BackupSchedule, window *blackout) (string, error) {
+	job := scheduleJob(tx, schedule)
+	if window != nil {
+		var deferred models.Job
+		err := tx.Where("schedule_id = ? AND status = ? AND retry_of = ? AND run_after = ?",
+			schedule.ID, jobs.StatusQueued, "", window.Until).First(&deferred).Error
+		if err == nil {
+			return deferred.ID, nil
+		}
+		job.RunAfter = &window.Until
+	}
+
+	job, err := s.Jobs.EnqueueTx(tx, job)
+	return job.ID, err
+}
+
+// runKey normalizes a fire time so every trigger derives the same key.
+func runKey(fireTime time.Time) time.Time {
+	return fireTime.UTC().Truncate(time.Second)
+}
 
-	s.scheduleJobs[schedule.ID] = entryID
+func (s *Scheduler) AddSchedule(schedule models.BackupSchedule) {
+	if !schedule.Enabled {
+		return
+	}
 	s.CalculateAndUpdateNextRun(schedule)
 }
 
+// RemoveSchedule stops a schedule from firing until it is added again.
 func (s *Scheduler) RemoveSchedule(scheduleID string) {
-	if entryID, exists := s.scheduleJobs[scheduleID]; exists {
-		s.cron.Remove(entryID)
-		delete(s.scheduleJobs, scheduleID)
-	}
+	database.DB.Model(&models.BackupSchedule{}).Where("id = ?", scheduleID).Update("next_run", nil)
 }
 
 func (s *Scheduler) UpdateSchedule(schedule models.BackupSchedule) {
@@ -105,52 +279,34 @@ func (s *Scheduler) UpdateSchedule(schedule models.BackupSchedule) {
 	}
 }
 
-func (s *Scheduler) executeBackup(schedule models.BackupSchedule, db models.Database) {
-	backup, err := s.BackupExec.ExecuteBackup(db, schedule.ID)
+func (s *Scheduler) executeBackup(ctx context.Context, schedule models.BackupSchedule, db models.Database, opts backup.Options) (models.Backup, error) {
+	backup, err := s.BackupExec.ExecuteBackup(ctx, db, opts)
 	if err != nil {
 		database.DB.Create(&backup)
-		return
+		return backup, err
 	}
 
 	database.DB.Create(&backup)
+	CheckReplication(backup)
+	s.Prune(schedule, false)
 
 	now := time.Now()
 	database.UpdateScheduleLastRun(schedule.ID, now)
-	s.CalculateAndUpdateNextRun(schedule)
 
 	var dbModel models.Database
 	database.DB.First(&dbModel, "id = ?", db.ID)
 	dbModel.LastBackup = &now
 	dbModel.BackupCount++
 	database.DB.Save(&dbModel)
-}
 
-func (s *Scheduler) executeBackupNow(schedule models.BackupSchedule) {
-	var db models.Database
-	if err := database.DB.First(&db, "id = ?", schedule.DatabaseID).Error; err != nil {
-		return
-	}
-
-	s.executeBackup(schedule, db)
+	return backup, nil
 }
 
 func (s *Scheduler) CalculateAndUpdateNextRun(schedule models.BackupSchedule) {
-	expr := s.convertCronToStandard(schedule.CronExpression)
-	if expr == "" {
-		return
-	}
-
-	scheduleParser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
-	sched, err := scheduleParser.Parse(expr)
+	sched, err := scheduleOf(schedule)
 	if err != nil {
 		return
 	}
 
-	nextRun := sched.Next(time.Now())
-	database.UpdateScheduleNextRun(schedule.ID, nextRun)
-}
-
-func (s *Scheduler) convertCronToStandard(expr string) string {
-	return expr
+	database.UpdateScheduleNextRun(schedule.ID, nextRun(sched, time.Now()))
 }
-
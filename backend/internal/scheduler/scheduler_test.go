@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"path/filepath"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupDB(t *testing.T) {
+	t.Helper()
+	if err := database.InitDB(filepath.Join(t.TempDir(), "safebase.db")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dueSchedule(t *testing.T, cronExpr string, nextRun time.Time) models.BackupSchedule {
+	t.Helper()
+	nextRun = nextRun.UTC()
+	schedule := models.BackupSchedule{
+		ID:             "nightly",
+		DatabaseID:     "db",
+		DatabaseName:   "shop",
+		CronExpression: cronExpr,
+		Enabled:        true,
+		NextRun:        &nextRun,
+	}
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+func countRuns(t *testing.T) (runs, queued int64) {
+	t.Helper()
+	database.DB.Model(&models.ScheduleRun{}).Count(&runs)
+	database.DB.Model(&models.Job{}).Where("schedule_id = ?", "nightly").Count(&queued)
+	return runs, queued
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"0 2 * * *", "30 0 2 * * *", "@daily", "*/15 * * * 1-5"} {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("Expected %q to parse: %v", expr, err)
+		}
+	}
+	if _, err := ParseCron("every night"); err == nil {
+		t.Error("Expected an invalid expression to fail")
+	}
+
+	// 5-field expressions run at second 0, as before
+	sched, _ := ParseCron("0 2 * * *")
+	from := time.Date(2025, 3, 15, 12, 0, 0, 0, time.UTC)
+	if next := sched.Next(from); !next.Equal(time.Date(2025, 3, 16, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected next run at 02:00 the next day, got %s", next)
+	}
+}
+
+func TestTickFiresEachRunOnce(t *testing.T) {
+	setupDB(t)
+	now := time.Now()
+	dueSchedule(t, "0 2 * * *", now.Add(-time.Minute))
+
+	// Two scheduler instances ticking concurrently, several times each
+	schedulers := []*Scheduler{NewScheduler(t.TempDir()), NewScheduler(t.TempDir())}
+	var wg sync.WaitGroup
+	for _, s := range schedulers {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(s *Scheduler) {
+				defer wg.Done()
+				s.tick(now)
+			}(s)
+		}
+	}
+	wg.Wait()
+
+	if runs, queued := countRuns(t); runs != 1 || queued != 1 {
+		t.Fatalf("Expected exactly one run and one job, got %d runs and %d jobs", runs, queued)
+	}
+
+	var schedule models.BackupSchedule
+	database.DB.First(&schedule, "id = ?", "nightly")
+	if schedule.NextRun == nil || !schedule.NextRun.After(now) {
+		t.Errorf("Expected NextRun to move past now, got %v", schedule.NextRun)
+	}
+
+	// Nothing is due any more
+	schedulers[0].tick(now.Add(time.Second))
+	if runs, _ := countRuns(t); runs != 1 {
+		t.Errorf("Expected no further run before NextRun, got %d runs", runs)
+	}
+}
+
+func TestFireRejectsClaimedRun(t *testing.T) {
+	setupDB(t)
+	fireTime := time.Now().Add(-time.Minute)
+	schedule := dueSchedule(t, "* * * * *", fireTime)
+	s := NewScheduler(t.TempDir())
+
+	if fired, err := s.fire(schedule, fireTime, time.Now()); !fired || err != nil {
+		t.Fatalf("Expected the first trigger to fire, got %v, %v", fired, err)
+	}
+	// A trigger holding the stale schedule, with the fire time in another zone
+	if fired, err := s.fire(schedule, fireTime.In(time.FixedZone("UTC+2", 2*3600)), time.Now()); fired || err != nil {
+		t.Fatalf("Expected the claimed run not to fire again, got %v, %v", fired, err)
+	}
+
+	if runs, queued := countRuns(t); runs != 1 || queued != 1 {
+		t.Errorf("Expected exactly one run and one job, got %d runs and %d jobs", runs, queued)
+	}
+}
+
+func TestLeaderElection(t *testing.T) {
+	setupDB(t)
+
+	first, second := NewScheduler(t.TempDir()), NewScheduler(t.TempDir())
+	first.campaign()
+	second.campaign()
+	if !first.IsLeader() || second.IsLeader() {
+		t.Fatalf("Expected only the first replica to lead, got %v and %v", first.IsLeader(), second.IsLeader())
+	}
+
+	// Renewing keeps the lease; once the leader lets it go the other takes over
+	first.campaign()
+	second.campaign()
+	if !first.IsLeader() || second.IsLeader() {
+		t.Fatal("Expected the leader to keep its lease")
+	}
+	if err := database.ReleaseLease(leaderLease, first.Jobs.ID); err != nil {
+		t.Fatal(err)
+	}
+	second.campaign()
+	first.campaign()
+	if first.IsLeader() || !second.IsLeader() {
+		t.Errorf("Expected the second replica to take over, got %v and %v", first.IsLeader(), second.IsLeader())
+	}
+}
+
+func TestMisfirePolicies(t *testing.T) {
+	// Down from before 02:00 to 05:30: the 02:00 to 05:00 runs were missed
+	now := time.Date(2025, 3, 15, 5, 30, 0, 0, time.Local)
+	nextRun := time.Date(2025, 3, 15, 2, 0, 0, 0, time.Local)
+
+	for policy, want := range map[string]struct{ queued, missed int64 }{
+		MisfireSkip:    {0, 4},
+		MisfireRunOnce: {1, 3},
+		MisfireRunAll:  {4, 0},
+	} {
+		t.Run(policy, func(t *testing.T) {
+			setupDB(t)
+			schedule := dueSchedule(t, "0 * * * *", nextRun)
+			database.DB.Model(&schedule).Update("misfire_policy", policy)
+
+			s := NewScheduler(t.TempDir())
+			s.tick(now)
+			s.tick(now)
+
+			var missed, missedBackups, alerts int64
+			database.DB.Model(&models.ScheduleRun{}).Where("status = ?", RunMissed).Count(&missed)
+			database.DB.Model(&models.Backup{}).Where("status = ?", "missed").Count(&missedBackups)
+			database.DB.Model(&models.Alert{}).Count(&alerts)
+			_, queued := countRuns(t)
+
+			if queued != want.queued || missed != want.missed || missedBackups != want.missed {
+				t.Errorf("Expected %d runs queued and %d missed, got %d queued, %d missed runs and %d missed backups",
+					want.queued, want.missed, queued, missed, missedBackups)
+			}
+			if (alerts > 0) != (want.missed > 0) {
+				t.Errorf("Expected an alert only for skipped runs, got %d alerts", alerts)
+			}
+
+			database.DB.First(&schedule, "id = ?", schedule.ID)
+			if !schedule.NextRun.Equal(time.Date(2025, 3, 15, 6, 0, 0, 0, time.Local)) {
+				t.Errorf("Expected the next run at 06:00, got %s", schedule.NextRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"safebase-backend/internal/backup"
+	"safebase-backend/internal/database"
+	"safebase-backend/internal/models"
+	"time"
+)
+
+const (
+	defaultVerifyInterval = 24 * time.Hour
+	verifyCheckInterval   = time.Hour
+	verifyBatchSize       = 20
+)
+
+// startVerification periodically re-reads stored backups that have not been
+// verified within VerifyInterval.
+func (s *Scheduler) startVerification() {
+	go func() {
+		ticker := time.NewTicker(verifyCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if s.IsLeader() {
+				s.verifyDueBackups()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) verifyDueBackups() {
+	var backups []models.Backup
+	cutoff := time.Now().UTC().Add(-s.VerifyInterval)
+	err := database.DB.Where("status = ? AND (verified_at IS NULL OR verified_at < ?)", "success", cutoff).
+		Order("verified_at").Limit(verifyBatchSize).Find(&backups).Error
+	if err != nil {
+		log.Printf("Failed to load backups to verify: %v", err)
+		return
+	}
+
+	for _, b := range backups {
+		if err := s.VerifyBackup(&b); err != nil {
+			log.Printf("Verification of backup %s failed: %v", b.ID, err)
+		}
+	}
+}
+
+// VerifyBackup checks the stored artifact of b and records the outcome. A
+// damaged artifact marks the backup as corrupted and raises an alert; when
+// the check cannot run (storage unreachable, missing key) the backup is left
+// unchanged and the error returned.
+func (s *Scheduler) VerifyBackup(b *models.Backup) error {
+	err := s.BackupExec.Verify(*b)
+
+	var corruption *backup.CorruptionError
+	if err != nil && !errors.As(err, &corruption) {
+		return err
+	}
+
+	now := time.Now().UTC()
+	b.VerifiedAt = &now
+	if corruption == nil && b.Status == "corrupted" {
+		b.Status = "success"
+		b.Error = ""
+	}
+	if corruption != nil {
+		b.Status = "corrupted"
+		b.Error = corruption.Error()
+		database.CreateAlert("error", "Backup corrupted",
+			fmt.Sprintf("Backup of %s is corrupted: %s", b.CreatedAt.Format("2006-01-02 15:04"), corruption.Reason),
+			b.DatabaseName)
+	}
+
+	return database.DB.Model(&models.Backup{}).Where("id = ?", b.ID).
+		Updates(map[string]interface{}{"status": b.Status, "error": b.Error, "verified_at": b.VerifiedAt}).Error
+}
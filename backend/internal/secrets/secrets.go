@@ -0,0 +1,234 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTTL = 5 * time.Minute
+	// Only environment variables with this prefix, and files within these
+	// directories, may be referenced, so that a database cannot be pointed
+	// at the server's own keys or tokens
+	DefaultEnvPrefix = "SAFEBASE_SECRET_"
+	DefaultFileDir   = "/run/secrets"
+	// Likewise only vault paths under this prefix, the default KV mount,
+	// may be read, rather than e.g. auth/token/lookup-self
+	DefaultVaultPathPrefix = "secret/"
+)
+
+// Provider looks up secrets in one store. The reference is what follows the
+// provider's scheme, e.g. SAFEBASE_SECRET_PROD in env:SAFEBASE_SECRET_PROD.
+type Provider interface {
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// Validator is implemented by providers that only allow some references.
+type Validator interface {
+	Validate(ref string) error
+}
+
+// Resolver resolves secret references of the form scheme:ref, caching each
+// value for TTL so a backup run does not query the store for every command.
+type Resolver struct {
+	TTL time.Duration
+
+	providers map[string]Provider
+	mu        sync.Mutex
+	cache     map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// NewResolver returns a resolver knowing the env and file providers, with
+// their default restrictions.
+func NewResolver(ttl time.Duration) *Resolver {
+	r := &Resolver{
+		TTL:       ttl,
+		providers: make(map[string]Provider),
+		cache:     make(map[string]cachedSecret),
+	}
+	r.Register("env", Env{Prefix: DefaultEnvPrefix})
+	r.Register("file", File{Dirs: []string{DefaultFileDir}})
+	return r
+}
+
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+func (r *Resolver) provider(ref string) (Provider, string, error) {
+	scheme, path, ok := strings.Cut(ref, ":")
+	if !ok || path == "" {
+		return nil, "", fmt.Errorf("invalid secret reference %q, expected scheme:path", ref)
+	}
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown secret provider: %s", scheme)
+	}
+	if validator, ok := provider.(Validator); ok {
+		if err := validator.Validate(path); err != nil {
+			return nil, "", err
+		}
+	}
+	return provider, path, nil
+}
+
+// Validate checks that ref names a configured provider and is allowed by
+// it, without resolving it.
+func (r *Resolver) Validate(ref string) error {
+	_, _, err := r.provider(ref)
+	return err
+}
+
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.cache[ref]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	provider, path, err := r.provider(ref)
+	if err != nil {
+		return "", err
+	}
+	value, err := provider.Resolve(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %v", ref, err)
+	}
+
+	if r.TTL > 0 {
+		r.mu.Lock()
+		r.cache[ref] = cachedSecret{value: value, expires: time.Now().Add(r.TTL)}
+		r.mu.Unlock()
+	}
+	return value, nil
+}
+
+// LoadFromEnv builds the resolver: SECRET_CACHE_TTL sets the cache TTL,
+// SECRET_ENV_PREFIX the prefix of the environment variables that may be
+// referenced, SECRET_FILE_DIRS the comma separated directories of the files
+// that may be, and VAULT_ADDR, with VAULT_TOKEN and optionally
+// VAULT_NAMESPACE, enables the vault provider for the paths under
+// VAULT_PATH_PREFIX.
+func LoadFromEnv() (*Resolver, error) {
+	ttl := DefaultTTL
+	if value := os.Getenv("SECRET_CACHE_TTL"); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil || ttl < 0 {
+			return nil, fmt.Errorf("invalid SECRET_CACHE_TTL: %s", value)
+		}
+	}
+
+	r := NewResolver(ttl)
+	if prefix := os.Getenv("SECRET_ENV_PREFIX"); prefix != "" {
+		r.Register("env", Env{Prefix: prefix})
+	}
+	if dirs := os.Getenv("SECRET_FILE_DIRS"); dirs != "" {
+		var file File
+		for _, dir := range strings.Split(dirs, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				if !filepath.IsAbs(dir) {
+					return nil, fmt.Errorf("invalid SECRET_FILE_DIRS: %s is not an absolute path", dir)
+				}
+				file.Dirs = append(file.Dirs, filepath.Clean(dir))
+			}
+		}
+		r.Register("file", file)
+	}
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		prefix := os.Getenv("VAULT_PATH_PREFIX")
+		if prefix == "" {
+			prefix = DefaultVaultPathPrefix
+		}
+		r.Register("vault", &Vault{
+			Addr:       addr,
+			Token:      os.Getenv("VAULT_TOKEN"),
+			Namespace:  os.Getenv("VAULT_NAMESPACE"),
+			PathPrefix: prefix,
+		})
+	}
+	return r, nil
+}
+
+// Env reads a secret from an environment variable of the server whose name
+// starts with Prefix.
+type Env struct {
+	Prefix string
+}
+
+func (e Env) Validate(name string) error {
+	if e.Prefix == "" || !strings.HasPrefix(name, e.Prefix) || name == e.Prefix {
+		return fmt.Errorf("environment variable %s is not allowed, secret variables must start with %s", name, e.Prefix)
+	}
+	return nil
+}
+
+func (e Env) Resolve(ctx context.Context, name string) (string, error) {
+	if err := e.Validate(name); err != nil {
+		return "", err
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// File reads a secret from a file within Dirs, such as a Docker or
+// Kubernetes secret mount. A trailing newline is not part of the secret.
+type File struct {
+	Dirs []string
+}
+
+func (f File) Validate(path string) error {
+	if filepath.IsAbs(path) {
+		for _, dir := range f.Dirs {
+			if within(dir, filepath.Clean(path)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("file %s is not allowed, secret files must be within %s", path, strings.Join(f.Dirs, ", "))
+}
+
+func (f File) Resolve(ctx context.Context, path string) (string, error) {
+	if err := f.Validate(path); err != nil {
+		return "", err
+	}
+	// A symlink in a secret directory must not lead out of it either
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	allowed := false
+	for _, dir := range f.Dirs {
+		if realDir, err := filepath.EvalSymlinks(dir); err == nil && within(realDir, resolved) {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("file %s is not allowed, it links outside the secret directories", path)
+	}
+
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// within reports whether path is strictly inside dir.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
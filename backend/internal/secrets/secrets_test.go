@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveEnvAndFile(t *testing.T) {
+	t.Setenv("SAFEBASE_SECRET_TEST_PASSWORD", "from-env")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "db-password")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver(0)
+	r.Register("file", File{Dirs: []string{dir}})
+	for ref, want := range map[string]string{"env:SAFEBASE_SECRET_TEST_PASSWORD": "from-env", "file:" + path: "from-file"} {
+		if got, err := r.Resolve(context.Background(), ref); got != want || err != nil {
+			t.Errorf("Expected %s to resolve to %q, got %q, %v", ref, want, got, err)
+		}
+	}
+
+	for _, ref := range []string{"env:SAFEBASE_SECRET_TEST_UNSET", "vault:secret/data/db", "no-scheme"} {
+		if _, err := r.Resolve(context.Background(), ref); err == nil {
+			t.Errorf("Expected %s not to resolve", ref)
+		}
+	}
+}
+
+func TestRejectEnvOutsidePrefix(t *testing.T) {
+	t.Setenv("BACKUP_ENCRYPTION_KEYS", "master-key")
+	t.Setenv("VAULT_TOKEN", "root")
+
+	r := NewResolver(0)
+	for _, ref := range []string{"env:BACKUP_ENCRYPTION_KEYS", "env:VAULT_TOKEN", "env:SAFEBASE_SECRET_"} {
+		if err := r.Validate(ref); err == nil {
+			t.Errorf("Expected %s to be rejected", ref)
+		}
+		if value, err := r.Resolve(context.Background(), ref); err == nil {
+			t.Errorf("Expected %s not to resolve, got %q", ref, value)
+		}
+	}
+}
+
+func TestRejectFileOutsideDirs(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "secrets")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(root, "credentials.key")
+	if err := os.WriteFile(key, []byte("master-key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(key, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver(0)
+	r.Register("file", File{Dirs: []string{dir}})
+	for _, ref := range []string{"file:" + key, "file:" + dir + "/../credentials.key", "file:/etc/passwd", "file:secrets/db"} {
+		if err := r.Validate(ref); err == nil {
+			t.Errorf("Expected %s to be rejected", ref)
+		}
+	}
+	// A link inside the directory passes validation but is not followed out
+	if value, err := r.Resolve(context.Background(), "file:"+filepath.Join(dir, "link")); err == nil {
+		t.Errorf("Expected a link out of the secret directory not to resolve, got %q", value)
+	}
+}
+
+func TestResolveVaultCached(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		if req.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/secret/data/prod/db":
+			w.Write([]byte(`{"data":{"data":{"password":"v2-secret","user":"app"},"metadata":{"version":3}}}`))
+		case "/v1/secret/prod/db":
+			w.Write([]byte(`{"data":{"pass":"v1-secret"}}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	r := NewResolver(time.Minute)
+	r.Register("vault", &Vault{Addr: server.URL, Token: "root", PathPrefix: DefaultVaultPathPrefix})
+
+	for ref, want := range map[string]string{"vault:secret/data/prod/db": "v2-secret", "vault:secret/prod/db#pass": "v1-secret"} {
+		for i := 0; i < 3; i++ {
+			if got, err := r.Resolve(context.Background(), ref); got != want || err != nil {
+				t.Fatalf("Expected %s to resolve to %q, got %q, %v", ref, want, got, err)
+			}
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected one vault request per secret, got %d", requests)
+	}
+
+	if _, err := r.Resolve(context.Background(), "vault:secret/data/missing"); err == nil {
+		t.Error("Expected a missing secret not to resolve")
+	}
+}
+
+func TestRejectVaultOutsidePrefix(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.Write([]byte(`{"data":{"id":"root","password":"leaked"}}`))
+	}))
+	defer server.Close()
+
+	r := NewResolver(0)
+	r.Register("vault", &Vault{Addr: server.URL, Token: "root", PathPrefix: "secret/data/safebase"})
+	for _, ref := range []string{
+		"vault:auth/token/lookup-self#id",
+		"vault:secret/data/other/db",
+		"vault:secret/data/safebase",
+		"vault:secret/data/safebase-other/db",
+		"vault:secret/data/safebase/../../../auth/token/lookup-self#id",
+		"vault:secret/data/safebase//db",
+		"vault:secret/data/safebase/%2e%2e/other",
+		"vault:secret/data/safebase/db?version=1",
+	} {
+		if err := r.Validate(ref); err == nil {
+			t.Errorf("Expected %s to be rejected", ref)
+		}
+		if value, err := r.Resolve(context.Background(), ref); err == nil {
+			t.Errorf("Expected %s not to resolve, got %q", ref, value)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request to vault, got %d", requests)
+	}
+
+	if value, err := r.Resolve(context.Background(), "vault:secret/data/safebase/db"); value != "leaked" || err != nil {
+		t.Errorf("Expected a path under the prefix to resolve, got %q, %v", value, err)
+	}
+}
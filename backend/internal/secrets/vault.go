@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from a HashiCorp Vault KV engine over its HTTP API.
+// A reference is the API path of the secret, optionally followed by #field
+// (password by default): secret/data/prod/db#password for a KV v2 mount,
+// kv/prod/db for a KV v1 mount. Only paths under PathPrefix may be read.
+type Vault struct {
+	Addr       string
+	Token      string
+	Namespace  string
+	PathPrefix string
+	Client     *http.Client
+}
+
+func (v *Vault) Validate(ref string) error {
+	path, _, _ := strings.Cut(ref, "#")
+	prefix := strings.Trim(v.PathPrefix, "/")
+	if prefix == "" || !strings.HasPrefix(strings.TrimLeft(path, "/"), prefix+"/") {
+		return fmt.Errorf("vault path %s is not allowed, secret paths must be under %s/", path, prefix)
+	}
+	for _, segment := range strings.Split(strings.TrimLeft(path, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, "?%\\") {
+			return fmt.Errorf("invalid vault path %s", path)
+		}
+	}
+	return nil
+}
+
+func (v *Vault) Resolve(ctx context.Context, ref string) (string, error) {
+	if err := v.Validate(ref); err != nil {
+		return "", err
+	}
+	path, field, _ := strings.Cut(ref, "#")
+	if field == "" {
+		field = "password"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(v.Addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", v.Token)
+	if v.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.Namespace)
+	}
+
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", fmt.Errorf("invalid vault response: %v", err)
+	}
+
+	// KV v2 nests the secret under data.data, next to its metadata
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+		data = nested
+	}
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no %s field", path, field)
+	}
+	return value, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocal(t.TempDir())
+
+	if err := store.Put(ctx, "mysql/db_20250101.sql", strings.NewReader("dump")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	info, err := store.Stat(ctx, "mysql/db_20250101.sql")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size != 4 {
+		t.Errorf("Expected size 4, got %d", info.Size)
+	}
+
+	r, err := store.Get(ctx, "mysql/db_20250101.sql")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	content, _ := io.ReadAll(r)
+	r.Close()
+	if string(content) != "dump" {
+		t.Errorf("Expected 'dump', got '%s'", content)
+	}
+
+	objects, err := store.List(ctx, "mysql/")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Key != "mysql/db_20250101.sql" {
+		t.Errorf("Unexpected listing: %+v", objects)
+	}
+
+	key, err := KeyFromURI(store, store.URI("mysql/db_20250101.sql"))
+	if err != nil || key != "mysql/db_20250101.sql" {
+		t.Errorf("Expected key round trip through URI, got '%s' (%v)", key, err)
+	}
+
+	if err := store.Delete(ctx, "mysql/db_20250101.sql"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Stat(ctx, "mysql/db_20250101.sql"); err == nil {
+		t.Error("Expected Stat to fail after Delete")
+	}
+}
+
+func TestLocalStorageRejectsEscapingKeys(t *testing.T) {
+	store := NewLocal(t.TempDir())
+	if err := store.Put(context.Background(), "../outside.sql", strings.NewReader("x")); err == nil {
+		t.Error("Expected error for key escaping the storage root")
+	}
+}
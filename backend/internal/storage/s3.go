@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"safebase-backend/internal/models"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the size of each part of an upload. Streamed uploads
+// buffer one part in memory, and S3 allows at most 10,000 parts, so
+// artifacts are limited to about 625 GiB.
+const s3PartSize = 64 << 20
+
+// S3 stores artifacts in a bucket of any S3-compatible service (AWS, MinIO,
+// Ceph...). The target Path is used as a key prefix.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3(target models.StorageTarget) (*S3, error) {
+	if target.Endpoint == "" || target.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage requires an endpoint and a bucket")
+	}
+
+	client, err := minio.New(target.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(target.AccessKey, target.SecretKey, ""),
+		Secure: target.UseSSL,
+		Region: target.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(target.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{client: client, bucket: target.Bucket, prefix: prefix}, nil
+}
+
+// s3Error reports a missing object as fs.ErrNotExist, like the other
+// backends, so that a deleted artifact is told apart from a failing bucket.
+func s3Error(op, name string, err error) error {
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return err
+}
+
+func (s *S3) objectName(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.prefix + key, nil
+}
+
+// Put streams r as a multipart upload of s3PartSize parts, so the artifact
+// size does not need to be known in advance.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, name, r, -1, minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+		PartSize:    s3PartSize,
+	})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s3Error("get", name, err)
+	}
+	// GetObject is lazy: stat it so a missing key fails here rather than on
+	// the first read
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s3Error("get", name, err)
+	}
+	return object, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	name, err := s.objectName(key)
+	if err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, name, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects := []ObjectInfo{}
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:     strings.TrimPrefix(object.Key, s.prefix),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	name, err := s.objectName(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, s3Error("stat", name, err)
+	}
+	return ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3) URI(key string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + key
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"safebase-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestS3MissingKeyIsNotExist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if strings.HasSuffix(req.URL.Path, "/denied.dump") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+	}))
+	defer server.Close()
+
+	store, err := NewS3(models.StorageTarget{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Bucket:   "backups",
+		Region:   "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get(context.Background(), "missing.dump"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected Get of a missing key to fail with fs.ErrNotExist, got %v", err)
+	}
+	if _, err := store.Stat(context.Background(), "missing.dump"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected Stat of a missing key to fail with fs.ErrNotExist, got %v", err)
+	}
+
+	// Other errors are not mistaken for a missing artifact
+	if _, err := store.Get(context.Background(), "denied.dump"); err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected an access error, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"safebase-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpHandshakeTimeout bounds the SSH handshake, so an endpoint that accepts
+// connections but never answers does not hang the backup.
+const sftpHandshakeTimeout = 30 * time.Second
+
+// SFTP stores artifacts in a directory of a remote host. A connection is
+// opened per operation; the host key must be pinned on the target.
+type SFTP struct {
+	addr   string
+	root   string
+	config *ssh.ClientConfig
+}
+
+func NewSFTP(target models.StorageTarget) (*SFTP, error) {
+	if target.Endpoint == "" || target.Username == "" {
+		return nil, fmt.Errorf("sftp storage requires an endpoint and a username")
+	}
+	if target.HostKey == "" {
+		return nil, fmt.Errorf("sftp storage requires a pinned host key")
+	}
+
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(target.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid host key: %v", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if target.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(target.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if target.Password != "" {
+		auth = append(auth, ssh.Password(target.Password))
+	}
+
+	root := strings.TrimSuffix(target.Path, "/")
+	if root == "" {
+		root = "."
+	}
+
+	addr := target.Endpoint
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	return &SFTP{
+		addr: addr,
+		root: root,
+		config: &ssh.ClientConfig{
+			User:            target.Username,
+			Auth:            auth,
+			HostKeyCallback: ssh.FixedHostKey(hostKey),
+			Timeout:         sftpHandshakeTimeout,
+		},
+	}, nil
+}
+
+type sftpSession struct {
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+func (s *SFTP) connect(ctx context.Context) (*sftpSession, error) {
+	dialer := net.Dialer{Timeout: s.config.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// NewClientConn has no timeout of its own: bound the handshake with a
+	// deadline and abort it if ctx is cancelled
+	deadline := time.Now().Add(s.config.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	netConn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, s.addr, s.config)
+	if !stop() || err != nil {
+		netConn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+	conn := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sftpSession{conn: conn, client: client}, nil
+}
+
+func (ss *sftpSession) Close() error {
+	ss.client.Close()
+	return ss.conn.Close()
+}
+
+func (s *SFTP) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.root + "/" + key, nil
+}
+
+// Put uploads to a temporary name and renames it once complete.
+func (s *SFTP) Put(ctx context.Context, key string, r io.Reader) error {
+	dest, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if err := session.client.MkdirAll(path.Dir(dest)); err != nil {
+		return err
+	}
+
+	tmp := path.Join(path.Dir(dest), ".upload-"+path.Base(dest))
+	f, err := session.client.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.ReadFrom(r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		session.client.Remove(tmp)
+		return err
+	}
+
+	if err := session.client.PosixRename(tmp, dest); err != nil {
+		session.client.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+type sftpReader struct {
+	*sftp.File
+	session *sftpSession
+}
+
+func (r *sftpReader) Close() error {
+	r.File.Close()
+	return r.session.Close()
+}
+
+func (s *SFTP) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := session.client.Open(p)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return &sftpReader{File: f, session: session}, nil
+}
+
+func (s *SFTP) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return session.client.Remove(p)
+}
+
+func (s *SFTP) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	session, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	objects := []ObjectInfo{}
+	walker := session.client.Walk(s.root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if walker.Path() == s.root {
+				// Nothing uploaded yet
+				return objects, nil
+			}
+			return nil, err
+		}
+		info := walker.Stat()
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".upload-") {
+			continue
+		}
+
+		key := strings.TrimPrefix(walker.Path(), s.root+"/")
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		}
+	}
+	return objects, nil
+}
+
+func (s *SFTP) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	session, err := s.connect(ctx)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer session.Close()
+
+	info, err := session.client.Stat(p)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *SFTP) URI(key string) string {
+	return "sftp://" + s.config.User + "@" + s.addr + "/" + strings.TrimPrefix(s.root, "/") + "/" + key
+}
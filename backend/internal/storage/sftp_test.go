@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// silentServer accepts connections and never answers, like a firewall
+// holding the SSH handshake.
+func silentServer(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestSFTPHandshakeIsBounded(t *testing.T) {
+	s := &SFTP{
+		addr: silentServer(t),
+		root: ".",
+		config: &ssh.ClientConfig{
+			User:            "backup",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			Timeout:         200 * time.Millisecond,
+		},
+	}
+
+	start := time.Now()
+	if _, err := s.connect(context.Background()); err == nil {
+		t.Fatal("Expected the handshake to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the handshake timeout to apply, took %s", elapsed)
+	}
+
+	// Cancelling the job aborts the handshake before the timeout
+	s.config.Timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := s.connect(ctx); err != context.Canceled {
+		t.Fatalf("Expected the handshake to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to abort the handshake, took %s", elapsed)
+	}
+}
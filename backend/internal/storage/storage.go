@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"safebase-backend/internal/models"
+	"strings"
+	"time"
+)
+
+// Storage is a place where backup artifacts are kept. Keys are slash
+// separated paths relative to the root of the target.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// URI returns the location of key, e.g. s3://bucket/prefix/key. It is
+	// what gets stored in models.Backup.FilePath.
+	URI(key string) string
+}
+
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Open builds the Storage described by a configured target.
+func Open(target models.StorageTarget) (Storage, error) {
+	switch target.Type {
+	case "local":
+		return NewLocal(target.Path), nil
+	case "s3":
+		return NewS3(target)
+	case "sftp":
+		return NewSFTP(target)
+	default:
+		return nil, fmt.Errorf("unsupported storage type: %s", target.Type)
+	}
+}
+
+// KeyFromURI returns the key of uri within s, which must have produced it.
+func KeyFromURI(s Storage, uri string) (string, error) {
+	root := s.URI("")
+	if !strings.HasPrefix(uri, root) {
+		return "", fmt.Errorf("%s does not belong to storage %s", uri, root)
+	}
+	return strings.TrimPrefix(uri, root), nil
+}
+
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return cleaned, nil
+}
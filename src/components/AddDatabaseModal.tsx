@@ -15,6 +15,7 @@ export default function AddDatabaseModal({ onClose, onSuccess }: AddDatabaseModa
     port: 3306,
     username: '',
     password: '',
+    passwordRef: '',
     database: '',
   });
 
@@ -226,8 +227,23 @@ export default function AddDatabaseModal({ onClose, onSuccess }: AddDatabaseModa
                   value={formData.password}
                   onChange={handleChange}
                   placeholder="••••••••"
+                  className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-primary-500 focus:border-transparent disabled:bg-gray-100"
+                  required={!formData.passwordRef}
+                  disabled={!!formData.passwordRef}
+                />
+              </div>
+
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  Secret externe (optionnel)
+                </label>
+                <input
+                  type="text"
+                  name="passwordRef"
+                  value={formData.passwordRef}
+                  onChange={handleChange}
+                  placeholder="env:SAFEBASE_SECRET_DB, file:/run/secrets/db, vault:secret/data/db#password"
                   className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-primary-500 focus:border-transparent"
-                  required
                 />
               </div>
             </div>
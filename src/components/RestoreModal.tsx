@@ -1,6 +1,7 @@
 import { useState } from 'react';
 import { X, RotateCcw, AlertTriangle, Database } from 'lucide-react';
 import { Backup } from '../types';
+import { api } from '../services/api';
 import { format } from 'date-fns';
 import { fr } from 'date-fns/locale';
 
@@ -12,15 +13,22 @@ interface RestoreModalProps {
 export default function RestoreModal({ backup, onClose }: RestoreModalProps) {
   const [confirmed, setConfirmed] = useState(false);
   const [isRestoring, setIsRestoring] = useState(false);
+  const [error, setError] = useState<string | null>(null);
 
   const handleRestore = async () => {
     if (!confirmed) return;
     
     setIsRestoring(true);
-    // Simulate restore process
-    await new Promise(resolve => setTimeout(resolve, 2000));
-    setIsRestoring(false);
-    onClose();
+    setError(null);
+    try {
+      await api.backups.restore(backup.id);
+      alert('Restauration lancée');
+      onClose();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Erreur lors de la restauration');
+    } finally {
+      setIsRestoring(false);
+    }
   };
 
   return (
@@ -113,6 +121,12 @@ export default function RestoreModal({ backup, onClose }: RestoreModalProps) {
               </div>
             </div>
 
+            {error && (
+              <div className="bg-red-50 border border-red-200 rounded-lg p-3 text-sm text-red-800">
+                {error}
+              </div>
+            )}
+
             {/* Confirmation */}
             <label className="flex items-start gap-3 cursor-pointer">
               <input
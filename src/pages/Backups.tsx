@@ -243,6 +243,18 @@ export default function Backups() {
                         En cours
                       </span>
                     )}
+                    {backup.status === 'corrupted' && (
+                      <span className="badge bg-red-100 text-red-800 flex items-center gap-1 w-fit">
+                        <XCircle className="w-3 h-3" />
+                        Corrompu
+                      </span>
+                    )}
+                    {backup.status === 'missed' && (
+                      <span className="badge bg-gray-100 text-gray-800 flex items-center gap-1 w-fit">
+                        <Clock className="w-3 h-3" />
+                        Manquée
+                      </span>
+                    )}
                   </td>
                   <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-600">
                     {format(backup.createdAt, "dd MMM yyyy HH:mm", { locale: fr })}
@@ -29,6 +29,8 @@ export default function Databases() {
         return 'bg-gray-100 text-gray-800';
       case 'error':
         return 'bg-red-100 text-red-800';
+      case 'unknown':
+        return 'bg-yellow-100 text-yellow-800';
     }
   };
 
@@ -40,6 +42,8 @@ export default function Databases() {
         return 'Déconnectée';
       case 'error':
         return 'Erreur';
+      case 'unknown':
+        return 'Vérification…';
     }
   };
 
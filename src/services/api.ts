@@ -1,3 +1,5 @@
+import { BlackoutWindow, Job } from '../types';
+
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8081/api';
 
 // Auth Token Management
@@ -95,6 +97,13 @@ export const api = {
     delete: (id: string) => fetchAPI<void>(`/databases/${id}`, {
       method: 'DELETE',
     }),
+    test: (data: any) => fetchAPI<any>('/databases/test', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+    testById: (id: string) => fetchAPI<any>(`/databases/${id}/test`, {
+      method: 'POST',
+    }),
   },
 
   schedules: {
@@ -111,7 +120,7 @@ export const api = {
     delete: (id: string) => fetchAPI<void>(`/schedules/${id}`, {
       method: 'DELETE',
     }),
-    execute: (id: string) => fetchAPI<any>(`/schedules/${id}/execute`, {
+    execute: (id: string) => fetchAPI<Job>(`/schedules/${id}/execute`, {
       method: 'POST',
     }),
   },
@@ -122,10 +131,76 @@ export const api = {
       return fetchAPI<any[]>(`/backups${params}`);
     },
     getById: (id: string) => fetchAPI<any>(`/backups/${id}`),
-    createManual: (databaseId: string) => fetchAPI<any>('/backups/manual', {
+    createManual: (databaseId: string) => fetchAPI<Job>('/backups/manual', {
       method: 'POST',
       body: JSON.stringify({ databaseId }),
     }),
+    restore: (id: string, targetDatabaseId?: string) => fetchAPI<Job>(`/backups/${id}/restore`, {
+      method: 'POST',
+      body: JSON.stringify({ targetDatabaseId }),
+    }),
+  },
+
+  jobs: {
+    getAll: (status?: string) => {
+      const params = status ? `?status=${status}` : '';
+      return fetchAPI<any[]>(`/jobs${params}`);
+    },
+    getById: (id: string) => fetchAPI<Job>(`/jobs/${id}`),
+    cancel: (id: string) => fetchAPI<Job>(`/jobs/${id}/cancel`, { method: 'POST' }),
+    // Streams the Server-Sent Events of a job; EventSource cannot send the
+    // Authorization header, so the stream is read through fetch.
+    events: async (id: string, onEvent: (type: string, data: any) => void, signal?: AbortSignal) => {
+      const response = await fetch(`${API_BASE_URL}/jobs/${id}/events`, {
+        headers: { Authorization: `Bearer ${authToken.get()}` },
+        signal,
+      });
+      if (!response.ok || !response.body) {
+        throw new Error(`API Error: ${response.statusText}`);
+      }
+
+      const reader = response.body.getReader();
+      const decoder = new TextDecoder();
+      let buffer = '';
+      for (;;) {
+        const { done, value } = await reader.read();
+        if (done) break;
+        buffer += decoder.decode(value, { stream: true });
+
+        let end: number;
+        while ((end = buffer.indexOf('\n\n')) >= 0) {
+          const message = buffer.slice(0, end);
+          buffer = buffer.slice(end + 2);
+          let type = 'message';
+          const data: string[] = [];
+          for (const line of message.split('\n')) {
+            if (line.startsWith('event:')) type = line.slice(6).trim();
+            else if (line.startsWith('data:')) data.push(line.slice(5).trimStart());
+          }
+          if (data.length > 0) {
+            onEvent(type, JSON.parse(data.join('\n')));
+          }
+        }
+      }
+    },
+  },
+
+  blackouts: {
+    getAll: (databaseId?: string) => {
+      const params = databaseId ? `?databaseId=${databaseId}` : '';
+      return fetchAPI<BlackoutWindow[]>(`/blackouts${params}`);
+    },
+    create: (data: Partial<BlackoutWindow>) => fetchAPI<BlackoutWindow>('/blackouts', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+    update: (id: string, data: Partial<BlackoutWindow>) => fetchAPI<BlackoutWindow>(`/blackouts/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+    delete: (id: string) => fetchAPI<void>(`/blackouts/${id}`, {
+      method: 'DELETE',
+    }),
   },
 
   alerts: {
@@ -1,14 +1,26 @@
 export interface Database {
   id: string;
   name: string;
-  type: 'mysql' | 'postgresql';
+  type: 'mysql' | 'postgresql' | 'mongodb' | 'sqlite';
   host: string;
   port: number;
   username: string;
-  status: 'connected' | 'disconnected' | 'error';
+  hasPassword?: boolean;
+  passwordRef?: string;
+  sshHost?: string;
+  sshUser?: string;
+  sshUseAgent?: boolean;
+  sshKnownHosts?: string;
+  hasSshPrivateKey?: boolean;
+  status: 'connected' | 'disconnected' | 'error' | 'unknown';
   lastBackup?: Date;
   backupCount: number;
   size: string;
+  sizeBytes?: number;
+  serverVersion?: string;
+  statusError?: string;
+  lastCheckedAt?: Date;
+  timeoutMinutes?: number;
   createdAt: Date;
 }
 
@@ -16,12 +28,30 @@ export interface Backup {
   id: string;
   databaseId: string;
   databaseName: string;
+  scheduleId?: string;
   version: string;
   size: string;
-  status: 'success' | 'failed' | 'in_progress';
+  status: 'success' | 'failed' | 'in_progress' | 'cancelled' | 'missed' | 'corrupted';
   createdAt: Date;
   duration: number;
   type: 'manual' | 'scheduled';
+  compression?: 'none' | 'gzip' | 'zstd';
+  compressionRatio?: number;
+  checksum?: string;
+  verifiedAt?: Date;
+  replicas?: BackupReplica[];
+  requiredCopies?: number;
+  retryOf?: string;
+  retry?: number;
+  waitDuration?: number;
+}
+
+export interface BackupReplica {
+  id: string;
+  storageTargetId: string;
+  status: 'success' | 'failed';
+  error?: string;
+  attempts: number;
 }
 
 export interface BackupSchedule {
@@ -32,6 +62,87 @@ export interface BackupSchedule {
   enabled: boolean;
   nextRun: Date;
   lastRun?: Date;
+  timezone?: string;
+  type?: 'backup' | 'restore_test';
+  sandboxDatabaseId?: string;
+  sanityChecks?: SanityCheck[];
+  misfirePolicy?: 'skip' | 'run_once' | 'run_all';
+  timeoutMinutes?: number;
+  retryMaxAttempts?: number;
+  retryDelaySeconds?: number;
+  retryBackoff?: number;
+  retryJitter?: number;
+  keepLast?: number;
+  keepDaily?: number;
+  keepWeekly?: number;
+  keepMonthly?: number;
+  maxTotalSizeBytes?: number;
+}
+
+export interface BlackoutWindow {
+  id: string;
+  name: string;
+  databaseId?: string;
+  action: 'defer' | 'skip';
+  startsAt?: Date;
+  endsAt?: Date;
+  days?: number[];
+  startTime?: string;
+  endTime?: string;
+  timezone?: string;
+  createdAt: Date;
+}
+
+export interface SanityCheck {
+  query: string;
+  expect?: string;
+  min?: number;
+}
+
+export interface RestoreDrill {
+  id: string;
+  scheduleId: string;
+  databaseId: string;
+  databaseName: string;
+  backupId: string;
+  sandboxDatabaseId: string;
+  status: 'passed' | 'failed';
+  restoreDuration: number;
+  duration: number;
+  checks: (SanityCheck & { value: string; passed: boolean; error?: string })[];
+  error?: string;
+  createdAt: Date;
+}
+
+export interface Job {
+  id: string;
+  type: 'backup' | 'schedule' | 'restore';
+  databaseId?: string;
+  scheduleId?: string;
+  backupId?: string;
+  status: 'queued' | 'running' | 'success' | 'failed' | 'cancelled';
+  resultId?: string;
+  error?: string;
+  attempts: number;
+  createdAt: Date;
+  startedAt?: Date;
+  finishedAt?: Date;
+  retryOf?: string;
+  retry?: number;
+  runAfter?: Date;
+  host?: string;
+  storage?: string;
+  workerId?: string;
+  leaseUntil?: Date;
+}
+
+export interface JobProgressEvent {
+  type: 'phase' | 'bytes' | 'log';
+  phase?: 'connecting' | 'dumping' | 'compressing' | 'uploading' | 'verifying' | 'replicating';
+  rawBytes?: number;
+  bytes?: number;
+  line?: string;
+  time: Date;
 }
 
 export interface Alert {
@@ -53,3 +164,20 @@ export interface DashboardStats {
   recentAlerts: Alert[];
 }
 
+
+export interface PermissionCheck {
+  name: string;
+  passed: boolean;
+  error?: string;
+}
+
+export interface ConnectionReport {
+  connected: boolean;
+  canBackup: boolean;
+  version?: string;
+  latencyMs: number;
+  sizeBytes: number;
+  size?: string;
+  permissions: PermissionCheck[];
+  error?: string;
+}